@@ -0,0 +1,94 @@
+// mvcc-server 通過 TCP 提供 mvcc.Database
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/httpapi"
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/resp"
+	"github.com/Mahopanda/golang-mvcc/pkg/server"
+)
+
+func main() {
+	addr := flag.String("addr", ":7070", "監聽地址")
+	httpAddr := flag.String("http-addr", "", "HTTP/JSON API 的監聽地址，空字串表示不啟用")
+	respAddr := flag.String("resp-addr", "", "Redis 協議前端的監聽地址，空字串表示不啟用")
+	dir := flag.String("dir", "", "數據目錄，空字串表示只保存在內存中")
+	lockTimeout := flag.Duration("lock-timeout", mvcc.DefaultLockTimeout, "等待鎖的最長時間")
+	maxTxLife := flag.Duration("max-tx-lifetime", 5*time.Minute, "回滾存活時間超過此值的事務，0 表示不回收")
+	flag.Parse()
+
+	opts := []mvcc.Option{
+		mvcc.WithLockTimeout(*lockTimeout),
+		mvcc.WithMaxTransactionLifetime(*maxTxLife),
+	}
+	var db *mvcc.Database
+	if *dir == "" {
+		db = mvcc.NewDatabase(opts...)
+	} else {
+		var err error
+		if db, err = mvcc.Open(*dir, opts...); err != nil {
+			log.Fatalf("open %s: %v", *dir, err)
+		}
+	}
+
+	l, err := net.Listen("tcp", *addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := server.New(db)
+
+	respSrv := resp.New(db)
+	if *respAddr != "" {
+		rl, err := net.Listen("tcp", *respAddr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("serving Redis protocol on %s", rl.Addr())
+		go func() {
+			if err := respSrv.Serve(rl); err != nil && !errors.Is(err, resp.ErrServerClosed) {
+				log.Print(err)
+			}
+		}()
+	}
+
+	httpSrv := &http.Server{Addr: *httpAddr, Handler: httpapi.New(db)}
+	if *httpAddr != "" {
+		log.Printf("serving HTTP API on %s", *httpAddr)
+		go func() {
+			if err := httpSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Print(err)
+			}
+		}()
+	}
+
+	// 收到信號時停止接受連線，Serve 隨之返回
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		srv.Close()
+	}()
+
+	log.Printf("listening on %s", l.Addr())
+	if err := srv.Serve(l); err != nil && !errors.Is(err, server.ErrServerClosed) {
+		log.Print(err)
+	}
+	// 等待所有連線上的事務回滾之後才關閉數據庫
+	srv.Close()
+	respSrv.Close()
+	httpSrv.Shutdown(context.Background())
+	if err := db.Close(); err != nil {
+		log.Print(err)
+	}
+}
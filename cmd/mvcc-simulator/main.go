@@ -1,72 +1,100 @@
-// Individual file modifications for the MVCC project
-
+// mvcc-simulator 是一個交互式的命令行，可以手動交錯執行多個具名事務，
+// 每一步之後顯示涉及的鍵的版本鏈與鎖表，適合用來教學或重現隔離級別的問題：
+//
+//	> begin t1 rr
+//	> begin t2 rc
+//	> write t1 k v1
+//	> read t2 k
+//	> commit t1
+//	> read t2 k
+//
+// 輸入 help 查看所有命令。給出場景文件時依次執行每個場景並報告每一步是否符合期望，
+// 有場景失敗時以狀態 1 退出（場景文件的格式見 simulator.Scenario，例子見 scenarios 目錄）：
+//
+//	mvcc-simulator scenarios/*.sim
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
-	"log"
-	"sync"
+	"io"
+	"os"
+	"time"
 
 	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/simulator"
 )
 
 func main() {
-	db := mvcc.NewDatabase()
-
-	// Simulate basic transactions
-	fmt.Println("=== Step 1: Simulate Transactions ===")
-	tx1 := db.Begin(mvcc.ReadCommitted)
-	db.Write(tx1, "key1", "value1")
-	fmt.Println("Transaction 1 wrote 'value1'.")
+	lockTimeout := flag.Duration("lock-timeout", 10*time.Minute, "被阻塞的操作放棄等待鎖之前的時間")
+	quiet := flag.Bool("quiet", false, "不在每一步之後顯示版本鏈與鎖表")
+	verbose := flag.Bool("v", false, "執行場景時同時輸出模擬器的結果")
+	flag.Parse()
 
-	tx2 := db.Begin(mvcc.ReadCommitted)
-	value, err := db.ReadWithIsolation(tx2, "key1", mvcc.ReadCommitted)
-	if err != nil {
-		fmt.Printf("Transaction 2 failed to read: %v\n", err)
-	} else {
-		fmt.Printf("Transaction 2 read (Committed): %s\n", value)
+	if flag.NArg() > 0 {
+		if !runScenarios(flag.Args(), *lockTimeout, *verbose && !*quiet) {
+			os.Exit(1)
+		}
+		return
 	}
 
-	err = db.Commit(tx1)
-	if err != nil {
-		fmt.Printf("Failed to commit Transaction 1: %v\n", err)
-	} else {
-		fmt.Println("Transaction 1 committed.")
-	}
+	db := mvcc.NewDatabase(mvcc.WithLockTimeout(*lockTimeout))
+	defer db.Close()
+	session := simulator.NewSession(db, os.Stdout)
+	session.Show = !*quiet
+	defer session.Close()
 
-	// Simulate concurrent transactions
-	fmt.Println("=== Step 2: Simulate Concurrent Transactions ===")
-	var wg sync.WaitGroup
-	wg.Add(2)
+	// 標準輸入是終端時才顯示提示符，方便從文件或管道輸入命令
+	interactive := false
+	if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		interactive = true
+	}
 
-	go func() {
-		defer wg.Done()
-		tx := db.Begin(mvcc.ReadCommitted)
-		db.Write(tx, "key1", "value2")
-		db.Commit(tx)
-		log.Println("Transaction 3 wrote 'value2' and committed.")
-	}()
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		if interactive {
+			fmt.Print("> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := scanner.Text()
+		if line == "quit" || line == "exit" {
+			break
+		}
+		if err := session.Exec(line); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
 
-	go func() {
-		defer wg.Done()
-		tx := db.Begin(mvcc.ReadCommitted)
-		db.Write(tx, "key1", "value3")
-		db.Commit(tx)
-		log.Println("Transaction 4 wrote 'value3' and committed.")
-	}()
+// runScenarios 在各自的新數據庫上執行每個場景文件，全部通過時返回 true
+func runScenarios(paths []string, lockTimeout time.Duration, verbose bool) bool {
+	passed := true
+	for _, path := range paths {
+		sc, err := simulator.LoadScenario(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			passed = false
+			continue
+		}
 
-	wg.Wait()
+		trace := io.Discard
+		if verbose {
+			trace = os.Stdout
+		}
+		db := mvcc.NewDatabase(mvcc.WithLockTimeout(lockTimeout))
+		report := sc.Run(db, trace)
+		db.Close()
 
-	// Validate results
-	tx3 := db.Begin(mvcc.ReadCommitted)
-	value, err = db.ReadWithIsolation(tx3, "key1", mvcc.ReadCommitted)
-	if err != nil {
-		fmt.Printf("Transaction 5 failed to read: %v\n", err)
-	} else {
-		fmt.Printf("Transaction 5 read (Committed): %s\n", value)
+		report.Write(os.Stdout)
+		if report.Failed() > 0 {
+			passed = false
+		}
 	}
-
-	fmt.Println("=== Program Completed ===")
+	return passed
 }
-
-// Additional modifications will continue across the `pkg/mvcc/` folder and `tests/` files for detailed granularity.
@@ -0,0 +1,152 @@
+// mvcc-server 的 Go 客戶端
+package client
+
+import (
+	"bufio"
+	"net"
+	"sync"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/utils"
+	"github.com/Mahopanda/golang-mvcc/pkg/wire"
+)
+
+// Client 一個到 mvcc-server 的連線，可以被多個 goroutine 共用，請求依序發送。
+// 服務端返回的錯誤為 *wire.Error，可以用 errors.Is 與 mvcc 的哨兵錯誤比較。
+//
+//	c, err := client.Dial("localhost:7070")
+//	tx, err := c.Begin(mvcc.RepeatableRead)
+//	err = tx.Write("key1", "value1")
+//	err = tx.Commit()
+type Client struct {
+	mu   sync.Mutex
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// Dial 連線到 addr 上的服務端
+func Dial(addr string) (*Client, error) {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+// Close 關閉連線，服務端會回滾這個連線上未結束的事務
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// call 發送請求並等待回應
+func (c *Client) call(req []byte) (*wire.Decoder, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := wire.WriteFrame(c.conn, req); err != nil {
+		return nil, err
+	}
+	payload, err := wire.ReadFrame(c.r)
+	if err != nil {
+		return nil, err
+	}
+	return wire.DecodeResponse(payload)
+}
+
+// Tx 服務端事務的句柄，只在建立它的 Client 上有效
+type Tx struct {
+	c      *Client
+	handle int
+}
+
+// Begin 在服務端開始 level 隔離級別的事務
+func (c *Client) Begin(level mvcc.IsolationLevel) (*Tx, error) {
+	return c.begin(level, false)
+}
+
+// BeginReadOnly 在服務端開始只讀事務
+func (c *Client) BeginReadOnly() (*Tx, error) {
+	return c.begin(mvcc.RepeatableRead, true)
+}
+
+func (c *Client) begin(level mvcc.IsolationLevel, readOnly bool) (*Tx, error) {
+	req := utils.AppendUvarint([]byte{byte(wire.OpBegin)}, int(level))
+	d, err := c.call(wire.AppendBool(req, readOnly))
+	if err != nil {
+		return nil, err
+	}
+	handle := d.Int()
+	if d.Err != nil {
+		return nil, d.Err
+	}
+	return &Tx{c: c, handle: handle}, nil
+}
+
+func (tx *Tx) request(op wire.Op) []byte {
+	return utils.AppendUvarint([]byte{byte(op)}, tx.handle)
+}
+
+// Read 讀取 key
+func (tx *Tx) Read(key string) (string, error) {
+	d, err := tx.c.call(utils.AppendString(tx.request(wire.OpRead), key))
+	if err != nil {
+		return "", err
+	}
+	value := d.String()
+	return value, d.Err
+}
+
+// Write 寫入 key
+func (tx *Tx) Write(key, value string) error {
+	req := utils.AppendString(tx.request(wire.OpWrite), key)
+	_, err := tx.c.call(utils.AppendString(req, value))
+	return err
+}
+
+// Delete 刪除 key
+func (tx *Tx) Delete(key string) error {
+	_, err := tx.c.call(utils.AppendString(tx.request(wire.OpDelete), key))
+	return err
+}
+
+// Commit 提交事務，之後句柄失效
+func (tx *Tx) Commit() error {
+	_, err := tx.c.call(tx.request(wire.OpCommit))
+	return err
+}
+
+// Rollback 回滾事務，之後句柄失效
+func (tx *Tx) Rollback() error {
+	_, err := tx.c.call(tx.request(wire.OpRollback))
+	return err
+}
+
+// Scan 按升序掃描 [start, end]，語義與 mvcc.Database.Scan 相同
+func (tx *Tx) Scan(start, end string, limit int) ([]mvcc.KeyValue, error) {
+	return tx.scan(start, end, limit, false)
+}
+
+// ReverseScan 按降序掃描 [start, end]
+func (tx *Tx) ReverseScan(start, end string, limit int) ([]mvcc.KeyValue, error) {
+	return tx.scan(start, end, limit, true)
+}
+
+func (tx *Tx) scan(start, end string, limit int, reverse bool) ([]mvcc.KeyValue, error) {
+	req := utils.AppendString(tx.request(wire.OpScan), start)
+	req = utils.AppendString(req, end)
+	req = utils.AppendUvarint(req, max(limit, 0))
+	d, err := tx.c.call(wire.AppendBool(req, reverse))
+	if err != nil {
+		return nil, err
+	}
+
+	n := d.Int()
+	pairs := make([]mvcc.KeyValue, 0)
+	for i := 0; i < n && d.Err == nil; i++ {
+		pairs = append(pairs, mvcc.KeyValue{Key: d.String(), Value: d.String()})
+	}
+	if d.Err != nil {
+		return nil, d.Err
+	}
+	return pairs, nil
+}
@@ -0,0 +1,321 @@
+// 以 HTTP/JSON 提供 mvcc.Database 的事務資源
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+)
+
+// Handler 將事務作為資源提供：
+//
+//	POST   /tx                      開始事務，請求 {"isolation": "repeatable_read", "read_only": false}
+//	GET    /tx/{id}                 查看事務
+//	GET    /tx/{id}/keys            掃描，參數 start、end、limit、reverse
+//	GET    /tx/{id}/keys/{key...}   讀取
+//	PUT    /tx/{id}/keys/{key...}   寫入，請求 {"value": "..."}
+//	DELETE /tx/{id}/keys/{key...}   刪除
+//	POST   /tx/{id}/commit          提交
+//	POST   /tx/{id}/rollback        回滾
+//
+// 讀寫與提交使用請求的上下文，客戶端斷開或請求超時時事務被回滾。
+// 在其他地方結束的事務（被回收器回滾或作為死鎖犧牲者回滾）在下一次開始事務時不再保留。
+// 錯誤以 {"error": "...", "code": "..."} 返回，狀態碼見 statusOf。
+type Handler struct {
+	db  *mvcc.Database
+	mux *http.ServeMux
+
+	mu  sync.Mutex
+	txs map[int]*mvcc.Transaction
+}
+
+func New(db *mvcc.Database) *Handler {
+	h := &Handler{
+		db:  db,
+		mux: http.NewServeMux(),
+		txs: make(map[int]*mvcc.Transaction),
+	}
+	h.mux.HandleFunc("POST /tx", h.begin)
+	h.mux.HandleFunc("GET /tx/{id}", h.get)
+	h.mux.HandleFunc("GET /tx/{id}/keys", h.scan)
+	// 鍵可以包含 "/"，取路徑的其餘部分
+	h.mux.HandleFunc("GET /tx/{id}/keys/{key...}", h.read)
+	h.mux.HandleFunc("PUT /tx/{id}/keys/{key...}", h.write)
+	h.mux.HandleFunc("DELETE /tx/{id}/keys/{key...}", h.delete)
+	h.mux.HandleFunc("POST /tx/{id}/commit", h.commit)
+	h.mux.HandleFunc("POST /tx/{id}/rollback", h.rollback)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// txResponse 事務資源的表示
+type txResponse struct {
+	ID        int    `json:"id"`
+	Isolation string `json:"isolation"`
+	ReadOnly  bool   `json:"read_only"`
+	ReadTS    int    `json:"read_ts"`
+	CommitTS  int    `json:"commit_ts,omitempty"`
+	Status    string `json:"status"`
+}
+
+var statusNames = map[mvcc.TransactionStatus]string{
+	mvcc.Active:    "active",
+	mvcc.Committed: "committed",
+	mvcc.Aborted:   "aborted",
+}
+
+// newTxResponse 返回事務的表示；事務上有其他請求的操作進行中時等待它結束
+func newTxResponse(tx *mvcc.Transaction) txResponse {
+	status, commitTS := tx.State()
+	return txResponse{
+		ID:        tx.ID,
+		Isolation: tx.IsolationLevel.String(),
+		ReadOnly:  tx.ReadOnly,
+		ReadTS:    tx.ReadTS,
+		CommitTS:  commitTS,
+		Status:    statusNames[status],
+	}
+}
+
+type keyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (h *Handler) begin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Isolation string `json:"isolation"`
+		ReadOnly  bool   `json:"read_only"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", err)
+			return
+		}
+	}
+
+	var tx *mvcc.Transaction
+	if req.ReadOnly {
+		tx = h.db.BeginReadOnly()
+	} else {
+		level := mvcc.RepeatableRead
+		if req.Isolation != "" {
+			var err error
+			if level, err = mvcc.ParseIsolationLevel(req.Isolation); err != nil {
+				writeError(w, http.StatusBadRequest, "bad_request", err)
+				return
+			}
+		}
+		tx = h.db.Begin(level)
+	}
+
+	h.mu.Lock()
+	h.prune()
+	h.txs[tx.ID] = tx
+	h.mu.Unlock()
+
+	w.Header().Set("Location", fmt.Sprintf("/tx/%d", tx.ID))
+	writeJSON(w, http.StatusCreated, newTxResponse(tx))
+}
+
+// lookup 找到路徑中的事務，找不到時寫出錯誤並返回 nil
+func (h *Handler) lookup(w http.ResponseWriter, r *http.Request) *mvcc.Transaction {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_request", fmt.Errorf("invalid transaction id %q", r.PathValue("id")))
+		return nil
+	}
+	h.mu.Lock()
+	tx, exists := h.txs[id]
+	h.mu.Unlock()
+	if !exists {
+		h.fail(w, nil, fmt.Errorf("transaction %d: %w", id, mvcc.ErrInvalidTransaction))
+		return nil
+	}
+	return tx
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request) {
+	if tx := h.lookup(w, r); tx != nil {
+		writeJSON(w, http.StatusOK, newTxResponse(tx))
+	}
+}
+
+func (h *Handler) read(w http.ResponseWriter, r *http.Request) {
+	tx := h.lookup(w, r)
+	if tx == nil {
+		return
+	}
+	key := r.PathValue("key")
+	value, err := h.db.ReadCtx(r.Context(), tx, key)
+	if err != nil {
+		h.fail(w, tx, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, keyValue{Key: key, Value: value})
+}
+
+func (h *Handler) write(w http.ResponseWriter, r *http.Request) {
+	tx := h.lookup(w, r)
+	if tx == nil {
+		return
+	}
+	var req struct {
+		Value *string `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Value == nil {
+		writeError(w, http.StatusBadRequest, "bad_request", errors.New(`request body must be {"value": "..."}`))
+		return
+	}
+	key := r.PathValue("key")
+	if err := h.db.WriteCtx(r.Context(), tx, key, *req.Value); err != nil {
+		h.fail(w, tx, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, keyValue{Key: key, Value: *req.Value})
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request) {
+	tx := h.lookup(w, r)
+	if tx == nil {
+		return
+	}
+	if err := h.db.DeleteCtx(r.Context(), tx, r.PathValue("key")); err != nil {
+		h.fail(w, tx, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) scan(w http.ResponseWriter, r *http.Request) {
+	tx := h.lookup(w, r)
+	if tx == nil {
+		return
+	}
+	q := r.URL.Query()
+	limit := 0
+	if s := q.Get("limit"); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil {
+			writeError(w, http.StatusBadRequest, "bad_request", fmt.Errorf("invalid limit %q", s))
+			return
+		}
+	}
+	scan := h.db.ScanCtx
+	if reverse, _ := strconv.ParseBool(q.Get("reverse")); reverse {
+		scan = h.db.ReverseScanCtx
+	}
+
+	it, err := scan(r.Context(), tx, q.Get("start"), q.Get("end"), limit)
+	if err != nil {
+		h.fail(w, tx, err)
+		return
+	}
+	pairs := make([]keyValue, 0, it.Len())
+	for it.Next() {
+		pairs = append(pairs, keyValue{Key: it.Key(), Value: it.Value()})
+	}
+	writeJSON(w, http.StatusOK, pairs)
+}
+
+func (h *Handler) commit(w http.ResponseWriter, r *http.Request) {
+	tx := h.lookup(w, r)
+	if tx == nil {
+		return
+	}
+	err := h.db.CommitCtx(r.Context(), tx)
+	h.forget(tx.ID)
+	if err != nil {
+		h.fail(w, tx, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newTxResponse(tx))
+}
+
+func (h *Handler) rollback(w http.ResponseWriter, r *http.Request) {
+	tx := h.lookup(w, r)
+	if tx == nil {
+		return
+	}
+	err := h.db.Rollback(tx)
+	h.forget(tx.ID)
+	if err != nil {
+		h.fail(w, tx, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newTxResponse(tx))
+}
+
+func (h *Handler) forget(id int) {
+	h.mu.Lock()
+	delete(h.txs, id)
+	h.mu.Unlock()
+}
+
+// prune 刪除已經結束的事務，調用者持有 h.mu
+func (h *Handler) prune() {
+	for id, tx := range h.txs {
+		if !h.db.IsActive(tx) {
+			delete(h.txs, id)
+		}
+	}
+}
+
+// fail 寫出錯誤回應；事務已經結束（例如死鎖犧牲者或到期）時不再保留它
+func (h *Handler) fail(w http.ResponseWriter, tx *mvcc.Transaction, err error) {
+	if tx != nil && !h.db.IsActive(tx) {
+		h.forget(tx.ID)
+	}
+	status, code := statusOf(err)
+	writeError(w, status, code, err)
+}
+
+// errorStatuses 哨兵錯誤對應的狀態碼與錯誤碼；同時滿足多個錯誤時取排在前面的
+var errorStatuses = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{mvcc.ErrKeyNotFound, http.StatusNotFound, "key_not_found"},
+	{mvcc.ErrVersionNotFound, http.StatusNotFound, "key_not_found"},
+	{mvcc.ErrInvalidTransaction, http.StatusNotFound, "transaction_not_found"},
+	{mvcc.ErrTransactionExpired, http.StatusGone, "transaction_expired"},
+	{mvcc.ErrReadOnlyTransaction, http.StatusForbidden, "read_only_transaction"},
+	{mvcc.ErrWriteConflict, http.StatusConflict, "write_conflict"},
+	{mvcc.ErrSerializationFailure, http.StatusConflict, "serialization_failure"},
+	{mvcc.ErrDeadlock, http.StatusConflict, "deadlock"},
+	{mvcc.ErrLockTimeout, http.StatusConflict, "lock_timeout"},
+	{mvcc.ErrLockConflict, http.StatusConflict, "lock_conflict"},
+	{mvcc.ErrInvalidEncoding, http.StatusBadRequest, "invalid_encoding"},
+	{context.DeadlineExceeded, http.StatusGatewayTimeout, "deadline_exceeded"},
+	{context.Canceled, http.StatusRequestTimeout, "canceled"},
+}
+
+// statusOf 返回錯誤的狀態碼與錯誤碼，不屬於任何哨兵錯誤時為 500
+func statusOf(err error) (int, string) {
+	for _, e := range errorStatuses {
+		if errors.Is(err, e.err) {
+			return e.status, e.code
+		}
+	}
+	return http.StatusInternalServerError, "internal"
+}
+
+func writeError(w http.ResponseWriter, status int, code string, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error(), "code": code})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
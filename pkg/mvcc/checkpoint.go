@@ -0,0 +1,190 @@
+// 檢查點：定期將快照寫入檔案以縮短日誌重放時間
+package mvcc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/utils"
+)
+
+// 檢查點檔名中的序號是恢復時開始重放的日誌段序號
+const checkpointPattern = "checkpoint-%016d.ckpt"
+
+// checkpointVersion 快照中一個鍵的最新已提交版本
+type checkpointVersion struct {
+	key   string
+	value string
+	ts    int
+	txID  int
+}
+
+// checkpoint 一致性快照
+type checkpoint struct {
+	ts       int // 快照的讀取時間戳
+	seq      int // 快照之後的日誌從這個段開始
+	versions []checkpointVersion
+}
+
+// Checkpoint 將所有鍵的最新已提交版本寫入快照檔案，並刪除快照已涵蓋的舊日誌段。
+// 已刪除的鍵不寫入快照。只有切換日誌段與固定快照時間戳時持有 db.mu，
+// 遍歷與寫檔期間其他事務照常讀寫提交。
+func (db *Database) Checkpoint() error {
+	db.checkpointMu.Lock()
+	defer db.checkpointMu.Unlock()
+
+	// 提交在持有 db.mu 時寫日誌並標記版本，因此在同一個鎖內切換日誌段並取得時間戳：
+	// 之前的提交全在舊段且時間戳不超過快照，之後的提交全在新段
+	db.mu.Lock()
+	if db.wal == nil {
+		db.mu.Unlock()
+		return ErrNotPersistent
+	}
+	seq, err := db.wal.rotate()
+	if err != nil {
+		db.mu.Unlock()
+		return err
+	}
+	ckpt := &checkpoint{ts: db.currentTS, seq: seq}
+	// 垃圾回收保留快照能看到的版本，直到遍歷結束
+	db.pendingCheckpoint = ckpt
+	w := db.wal
+	db.mu.Unlock()
+
+	// 之後提交的版本時間戳大於 ckpt.ts，遍歷時看不到
+	db.data.ascend("", func(key string, record *Record) bool {
+		version, err := record.GetVersion(ckpt.ts, RepeatableRead)
+		if err == nil && !version.Deleted {
+			ckpt.versions = append(ckpt.versions, checkpointVersion{
+				key:   key,
+				value: version.Value,
+				ts:    version.Timestamp,
+				txID:  version.TxID,
+			})
+		}
+		return true
+	})
+	db.mu.Lock()
+	db.pendingCheckpoint = nil
+	db.mu.Unlock()
+
+	if err := writeCheckpoint(w.dir, ckpt); err != nil {
+		return err
+	}
+	if err := removeCheckpointsBefore(w.dir, seq); err != nil {
+		return err
+	}
+	return w.removeBefore(seq)
+}
+
+// writeCheckpoint 先寫入臨時檔案並 fsync，再原子地改名
+func writeCheckpoint(dir string, ckpt *checkpoint) error {
+	payload := utils.AppendUvarint(nil, ckpt.ts)
+	payload = utils.AppendUvarint(payload, ckpt.seq)
+	payload = utils.AppendUvarint(payload, len(ckpt.versions))
+	for _, v := range ckpt.versions {
+		payload = utils.AppendString(payload, v.key)
+		payload = utils.AppendString(payload, v.value)
+		payload = utils.AppendUvarint(payload, v.ts)
+		payload = utils.AppendUvarint(payload, v.txID)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf(checkpointPattern, ckpt.seq))
+	tmp := path + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(appendFrame(nil, payload)); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// loadLatestCheckpoint 讀取最新的檢查點，沒有檢查點時返回 nil
+func loadLatestCheckpoint(dir string) (*checkpoint, error) {
+	seqs, err := listSeqs(dir, checkpointPattern)
+	if err != nil || len(seqs) == 0 {
+		return nil, err
+	}
+
+	seq := seqs[len(seqs)-1]
+	data, err := os.ReadFile(filepath.Join(dir, fmt.Sprintf(checkpointPattern, seq)))
+	if err != nil {
+		return nil, err
+	}
+	payload, _, ok := readFrame(data, 0)
+	if !ok {
+		return nil, fmt.Errorf("checkpoint %d: %w", seq, errCorruptEntry)
+	}
+
+	ckpt := &checkpoint{}
+	var n int
+	if ckpt.ts, payload, err = utils.ReadUvarint(payload); err != nil {
+		return nil, err
+	}
+	if ckpt.seq, payload, err = utils.ReadUvarint(payload); err != nil {
+		return nil, err
+	}
+	if n, payload, err = utils.ReadUvarint(payload); err != nil {
+		return nil, err
+	}
+	for i := 0; i < n; i++ {
+		var v checkpointVersion
+		if v.key, payload, err = utils.ReadString(payload); err != nil {
+			return nil, err
+		}
+		if v.value, payload, err = utils.ReadString(payload); err != nil {
+			return nil, err
+		}
+		if v.ts, payload, err = utils.ReadUvarint(payload); err != nil {
+			return nil, err
+		}
+		if v.txID, payload, err = utils.ReadUvarint(payload); err != nil {
+			return nil, err
+		}
+		ckpt.versions = append(ckpt.versions, v)
+	}
+	return ckpt, nil
+}
+
+// removeCheckpointsBefore 刪除比 seq 更舊的檢查點
+func removeCheckpointsBefore(dir string, seq int) error {
+	seqs, err := listSeqs(dir, checkpointPattern)
+	if err != nil {
+		return err
+	}
+	for _, s := range seqs {
+		if s >= seq {
+			break
+		}
+		if err := os.Remove(filepath.Join(dir, fmt.Sprintf(checkpointPattern, s))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreCheckpoint 將快照載入內存
+func (db *Database) restoreCheckpoint(ckpt *checkpoint) {
+	for _, v := range ckpt.versions {
+		record := NewRecord()
+		record.InsertVersion(v.value, v.txID)
+		record.CommitVersion(v.txID, v.ts)
+		db.data.put(v.key, record)
+		db.lastTxID = max(db.lastTxID, v.txID)
+	}
+	db.currentTS = max(db.currentTS, ckpt.ts)
+}
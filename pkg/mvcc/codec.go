@@ -0,0 +1,56 @@
+// 值編解碼
+package mvcc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+)
+
+// Codec 在值類型 V 與數據庫中保存的字節之間轉換
+type Codec[V any] interface {
+	Encode(v V) ([]byte, error)
+	Decode(data []byte) (V, error)
+}
+
+// StringCodec 原樣保存字串
+type StringCodec struct{}
+
+func (StringCodec) Encode(v string) ([]byte, error)    { return []byte(v), nil }
+func (StringCodec) Decode(data []byte) (string, error) { return string(data), nil }
+
+// BytesCodec 保存二進制數據
+type BytesCodec struct{}
+
+func (BytesCodec) Encode(v []byte) ([]byte, error) { return v, nil }
+
+func (BytesCodec) Decode(data []byte) ([]byte, error) {
+	return append([]byte(nil), data...), nil
+}
+
+// Int64Codec 以 8 字節大端序保存計數器等整數
+type Int64Codec struct{}
+
+func (Int64Codec) Encode(v int64) ([]byte, error) {
+	return binary.BigEndian.AppendUint64(nil, uint64(v)), nil
+}
+
+func (Int64Codec) Decode(data []byte) (int64, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("%w: int64 needs 8 bytes, got %d", ErrInvalidEncoding, len(data))
+	}
+	return int64(binary.BigEndian.Uint64(data)), nil
+}
+
+// JSONCodec 以 JSON 保存結構體等任意值
+type JSONCodec[V any] struct{}
+
+func (JSONCodec[V]) Encode(v V) ([]byte, error) { return json.Marshal(v) }
+
+func (JSONCodec[V]) Decode(data []byte) (V, error) {
+	var v V
+	if err := json.Unmarshal(data, &v); err != nil {
+		return v, fmt.Errorf("%w: %v", ErrInvalidEncoding, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,70 @@
+// 帶上下文的事務操作
+package mvcc
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TxOptions 配置 BeginTx 開始的事務
+type TxOptions struct {
+	Isolation   IsolationLevel
+	ReadOnly    bool          // 開始只讀事務，此時忽略 Isolation
+	LockTimeout time.Duration // 等待鎖的最長時間，0 表示使用數據庫的默認值
+}
+
+// BeginTx 開始綁定到 ctx 的事務。ctx 結束後，事務的下一次操作會回滾事務並返回包裝了 ctx.Err() 的錯誤，
+// 正在等待的鎖請求會立即放棄；各個 Ctx 方法的 ctx 同樣只作用於該次操作。
+func (db *Database) BeginTx(ctx context.Context, opts TxOptions) (*Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var tx *Transaction
+	if opts.ReadOnly {
+		tx = db.BeginReadOnly()
+	} else {
+		tx = db.Begin(opts.Isolation)
+	}
+	tx.LockTimeout = opts.LockTimeout
+	tx.ctx = ctx
+	return tx, nil
+}
+
+// bind 返回在 ctx 或事務的上下文結束時都會結束的上下文
+func (tx *Transaction) bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	if tx.ctx == nil || tx.ctx.Done() == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(tx.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// contextErr 返回事務的上下文或 ctx 結束的原因，都沒有結束時返回 nil
+func contextErr(ctx context.Context, tx *Transaction) error {
+	if tx.ctx != nil {
+		if err := tx.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	return ctx.Err()
+}
+
+// checkContext ctx 或事務的上下文已經結束時回滾事務
+func (db *Database) checkContext(ctx context.Context, tx *Transaction) error {
+	if err := contextErr(ctx, tx); err != nil {
+		return db.abortContext(tx, err)
+	}
+	return nil
+}
+
+// abortContext 因上下文結束而回滾事務，返回的錯誤帶有事務ID並包裝 err
+func (db *Database) abortContext(tx *Transaction, err error) error {
+	db.rollback(tx)
+	return fmt.Errorf("transaction %d aborted: %w", tx.ID, err)
+}
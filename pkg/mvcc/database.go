@@ -2,17 +2,37 @@
 package mvcc
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"os"
 	"sync"
+	"time"
 )
 
 // Database 定義MVCC數據庫
 type Database struct {
-	data        map[string]*Record
-	currentTS   int
+	data        *keyIndex // 按字典序排列的 key -> Record
+	currentTS   int       // 最近分配的提交時間戳，新事務以它作為快照
+	lastTxID    int
 	mu          sync.RWMutex
 	txManager   *TransactionManager
 	lockManager *LockManager
+	ssi         *ssiManager
+	wal         *wal // 為 nil 時不做持久化
+	lockTimeout time.Duration
+	retryPolicy RetryPolicy
+	maxTxLife   time.Duration // 事務的最長存活時間，0 表示不限制
+	gcPolicy    GCPolicy
+	gcTrigger   chan struct{}         // 版本鏈過長時通知後台垃圾回收
+	walFile     func(WALFile) WALFile // 包裝打開的日誌段，見 WithWALFile
+
+	checkpointMu      sync.Mutex
+	pendingCheckpoint *checkpoint // 正在遍歷的檢查點，垃圾回收不清理它的快照能看到的版本；由 db.mu 保護
+
+	stop       chan struct{}  // 關閉時通知後台 goroutine 退出
+	background sync.WaitGroup // 正在運行的後台 goroutine
+	closeOnce  sync.Once
 }
 
 // 新增事務管理器
@@ -22,12 +42,90 @@ type TransactionManager struct {
 }
 
 // NewDatabase 創建新數據庫實例
-func NewDatabase() *Database {
-	return &Database{
-		data:        make(map[string]*Record),
+func NewDatabase(opts ...Option) *Database {
+	db := &Database{
+		data:        newKeyIndex(),
 		txManager:   NewTransactionManager(),
 		lockManager: NewLockManager(),
+		ssi:         newSSIManager(),
+		lockTimeout: DefaultLockTimeout,
+		retryPolicy: DefaultRetryPolicy,
 	}
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	db.stop = make(chan struct{})
+	db.gcTrigger = make(chan struct{}, 1)
+	if db.maxTxLife > 0 {
+		db.background.Add(1)
+		go db.reapExpired()
+	}
+	if db.gcPolicy.enabled() {
+		db.background.Add(1)
+		go db.runGC()
+	}
+	return db
+}
+
+// Open 打開（或建立）dir 下的持久化數據庫，並重放預寫日誌恢復已提交的事務
+func Open(dir string, opts ...Option) (*Database, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	// 從最新的檢查點開始，只需重放其後的日誌段
+	ckpt, err := loadLatestCheckpoint(dir)
+	if err != nil {
+		return nil, err
+	}
+	db := NewDatabase(opts...)
+	fromSeq := 1
+	if ckpt != nil {
+		db.restoreCheckpoint(ckpt)
+		fromSeq = ckpt.seq
+	}
+
+	w, entries, err := openWAL(dir, fromSeq, db.walFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		db.replay(e)
+	}
+	db.wal = w
+	return db, nil
+}
+
+// replay 將一筆日誌記錄重新套用到內存中的版本鏈
+func (db *Database) replay(e walEntry) {
+	for _, w := range e.writes {
+		record := db.data.getOrCreate(w.key)
+		if w.op == walOpDelete {
+			record.InsertTombstone(e.txID)
+		} else {
+			record.InsertVersion(w.value, e.txID)
+		}
+		record.CommitVersion(e.txID, e.ts)
+	}
+	db.currentTS = max(db.currentTS, e.ts)
+	db.lastTxID = max(db.lastTxID, e.txID)
+}
+
+// Close 關閉數據庫，停止後台 goroutine 並釋放日誌檔案
+func (db *Database) Close() error {
+	db.closeOnce.Do(func() { close(db.stop) })
+	db.background.Wait()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.wal == nil {
+		return nil
+	}
+	err := db.wal.close()
+	db.wal = nil
+	return err
 }
 
 // Begin 開始新事務
@@ -35,62 +133,199 @@ func (db *Database) Begin(level IsolationLevel) *Transaction {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	db.currentTS++
-	tx := NewTransaction(db.currentTS, level)
+	db.lastTxID++
+	tx := NewTransaction(db.lastTxID, db.currentTS, level)
+	db.txManager.AddTransaction(tx)
+	if level == Serializable {
+		db.ssi.begin(tx.ID)
+	}
+	return tx
+}
+
+// BeginReadOnly 開始只讀事務：從開始時的快照讀取，不加鎖也不記錄讀集，
+// 提交時不會因驗證失敗而中止；Write 與 Delete 返回 ErrReadOnlyTransaction
+func (db *Database) BeginReadOnly() *Transaction {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.lastTxID++
+	tx := NewTransaction(db.lastTxID, db.currentTS, RepeatableRead)
+	tx.ReadOnly = true
 	db.txManager.AddTransaction(tx)
 	return tx
 }
 
 // Write 寫入數據
 func (db *Database) Write(tx *Transaction, key, value string) error {
-	if err := db.validateTransaction(tx); err != nil {
+	return db.WriteCtx(context.Background(), tx, key, value)
+}
+
+// WriteCtx 與 Write 相同，ctx 結束時放棄等待寫鎖並回滾事務
+func (db *Database) WriteCtx(ctx context.Context, tx *Transaction, key, value string) error {
+	if err := db.enter(tx); err != nil {
 		return err
 	}
+	defer tx.mu.Unlock()
+	if err := db.checkContext(ctx, tx); err != nil {
+		return err
+	}
+	if tx.ReadOnly {
+		return ErrReadOnlyTransaction
+	}
 
 	// 檢查寫鎖
-	if err := db.acquireLock(tx, key, WriteLock); err != nil {
+	if err := db.acquireLock(ctx, tx, key, WriteLock); err != nil {
 		return err
 	}
 
-	db.mu.Lock()
-	record, exists := db.data[key]
-	if !exists {
-		record = NewRecord()
-		db.data[key] = record
+	// 持有 db.mu 的讀鎖插入版本，垃圾回收不會在此期間移除記錄
+	db.mu.RLock()
+	record := db.data.getOrCreate(key)
+	err := record.InsertVersion(value, tx.ID)
+	db.mu.RUnlock()
+	if err != nil {
+		return err
 	}
-	db.mu.Unlock()
 
 	// 記錄寫集
+	tx.logWrite(key)
 	tx.WriteSet[key] = value
+	delete(tx.DeleteSet, key)
+
+	if tx.IsolationLevel == Serializable {
+		db.ssi.onWrite(tx.ID, key)
+	}
+	return nil
+}
+
+// Delete 刪除數據：插入墓碑版本，刪除之前開始的快照仍然能讀到舊值
+func (db *Database) Delete(tx *Transaction, key string) error {
+	return db.DeleteCtx(context.Background(), tx, key)
+}
+
+// DeleteCtx 與 Delete 相同，ctx 結束時放棄等待寫鎖並回滾事務
+func (db *Database) DeleteCtx(ctx context.Context, tx *Transaction, key string) error {
+	if err := db.enter(tx); err != nil {
+		return err
+	}
+	defer tx.mu.Unlock()
+	if err := db.checkContext(ctx, tx); err != nil {
+		return err
+	}
+	if tx.ReadOnly {
+		return ErrReadOnlyTransaction
+	}
+	if !db.visible(tx, key) {
+		return ErrKeyNotFound
+	}
+
+	held, holding := tx.locks[key]
+	if err := db.acquireLock(ctx, tx, key, WriteLock); err != nil {
+		return err
+	}
+	// 等待寫鎖期間其他事務可能已經提交了刪除，得到鎖之後再檢查一次
+	if !db.visible(tx, key) {
+		db.restoreLock(tx, key, held, holding)
+		return ErrKeyNotFound
+	}
+
+	db.mu.RLock()
+	record := db.data.getOrCreate(key)
+	err := record.InsertTombstone(tx.ID)
+	db.mu.RUnlock()
+	if err != nil {
+		return err
+	}
 
-	return record.InsertVersion(value, tx.WriteTS, tx.ID)
+	tx.logWrite(key)
+	tx.WriteSet[key] = ""
+	tx.DeleteSet[key] = struct{}{}
+
+	if tx.IsolationLevel == Serializable {
+		db.ssi.onWrite(tx.ID, key)
+	}
+	return nil
 }
 
-// Commit 提交事務
+// Commit 提交事務，驗證失敗時回滾並返回驗證錯誤
 func (db *Database) Commit(tx *Transaction) error {
+	return db.CommitCtx(context.Background(), tx)
+}
+
+// CommitCtx 與 Commit 相同；在寫入日誌之前 ctx 已經結束時回滾事務，之後提交不再可以取消
+func (db *Database) CommitCtx(ctx context.Context, tx *Transaction) error {
+	if err := db.enter(tx); err != nil {
+		return err
+	}
+	defer tx.mu.Unlock()
+	if err := db.checkContext(ctx, tx); err != nil {
+		return err
+	}
+	if tx.ReadOnly {
+		// 只讀事務沒有寫入需要提交，也不需要驗證
+		tx.Status = Committed
+		db.txManager.RemoveTransaction(tx.ID)
+		return nil
+	}
+
+	// 驗證與提交都在 db.mu 內完成，不會與其他事務的提交交錯
+	db.mu.Lock()
+
+	// 等待 db.mu 期間 ctx 可能已經結束
+	if err := contextErr(ctx, tx); err != nil {
+		db.mu.Unlock()
+		return db.abortContext(tx, err)
+	}
+
 	// First phase: Prepare
 	if err := db.prepare(tx); err != nil {
-		return db.Rollback(tx)
+		db.mu.Unlock()
+		db.rollback(tx)
+		return err
+	}
+
+	// 寫日誌之前確認每個鍵上都有本事務的未提交版本。持有 db.mu 時它們不會被移除，
+	// 因此日誌寫入之後提交版本不會失敗，日誌中不會留下內存中已經回滾的事務
+	records := make([]*Record, 0, len(tx.WriteSet))
+	for key := range tx.WriteSet {
+		record, exists := db.data.get(key)
+		if !exists || !record.hasUncommitted(tx.ID) {
+			db.mu.Unlock()
+			db.rollback(tx)
+			return fmt.Errorf("key %q: %w", key, ErrVersionNotFound)
+		}
+		records = append(records, record)
 	}
 
 	// Second phase: Commit
-	db.mu.Lock()
-	defer db.mu.Unlock()
+	// 分配提交時間戳，之後開始的事務才能看到本事務的寫入
+	db.currentTS++
+	tx.WriteTS = db.currentTS
+
+	// 先寫日誌並 fsync，之後才將版本標記為已提交
+	if db.wal != nil && len(tx.WriteSet) > 0 {
+		if err := db.wal.append(newWALEntry(tx)); err != nil {
+			db.mu.Unlock()
+			db.rollback(tx)
+			return err
+		}
+	}
 
 	// Commit changes for keys in WriteSet
-	for key := range tx.WriteSet {
-		record := db.data[key]
-		if err := record.CommitVersion(tx.ID); err != nil {
-			return db.Rollback(tx)
+	triggerGC := false
+	for _, record := range records {
+		record.CommitVersion(tx.ID, tx.WriteTS) // 上面已經確認版本存在
+		if limit := db.gcPolicy.MaxChainLength; limit > 0 && record.chainLength() > limit {
+			triggerGC = true
 		}
-		// Release write lock for this key
-		db.lockManager.ReleaseLock(tx.ID, key)
 	}
-
-	// Release read locks for keys in ReadSet
-	for key := range tx.ReadSet {
-		db.lockManager.ReleaseLock(tx.ID, key)
+	if triggerGC {
+		db.triggerGC()
 	}
+	defer db.mu.Unlock()
+
+	// 釋放事務持有的讀寫鎖，喚醒等待中的事務
+	db.lockManager.ReleaseAll(tx.ID)
 
 	tx.Status = Committed
 	db.txManager.RemoveTransaction(tx.ID)
@@ -98,10 +333,32 @@ func (db *Database) Commit(tx *Transaction) error {
 }
 
 func (db *Database) prepare(tx *Transaction) error {
+	// 快照隔離下先提交者勝出
+	if tx.IsolationLevel >= RepeatableRead {
+		for key := range tx.WriteSet {
+			if ts, conflict := db.hasWriteConflict(tx, key); conflict {
+				return &SerializationError{TxID: tx.ID, Key: key, ReadTS: tx.ReadTS, ConflictingTS: ts, Cause: ErrWriteConflict}
+			}
+		}
+	}
+
+	// Serializable 由 SSI 檢測危險結構，不逐一驗證讀集
+	if tx.IsolationLevel == Serializable {
+		if err := db.ssi.commit(tx.ID); err != nil {
+			return &SerializationError{TxID: tx.ID, ReadTS: tx.ReadTS, Cause: err}
+		}
+		return nil
+	}
+
+	// 讀未提交不保證讀到的數據穩定，不驗證讀集
+	if tx.IsolationLevel == ReadUncommitted {
+		return nil
+	}
+
 	// 驗證讀集
 	for key, ts := range tx.ReadSet {
-		if !db.validateReadSet(tx, key, ts) {
-			return ErrSerializationFailure
+		if newer, ok := db.validateReadSet(tx, key, ts); !ok {
+			return &SerializationError{TxID: tx.ID, Key: key, ReadTS: ts, ConflictingTS: newer, Cause: ErrSerializationFailure}
 		}
 	}
 	return nil
@@ -109,56 +366,68 @@ func (db *Database) prepare(tx *Transaction) error {
 
 // Read 讀取數據
 func (db *Database) Read(tx *Transaction, key string) (string, error) {
-	if err := db.validateTransaction(tx); err != nil {
+	return db.ReadCtx(context.Background(), tx, key)
+}
+
+// ReadCtx 與 Read 相同，ctx 結束時放棄等待讀鎖並回滾事務
+func (db *Database) ReadCtx(ctx context.Context, tx *Transaction, key string) (string, error) {
+	if err := db.enter(tx); err != nil {
 		return "", err
 	}
+	defer tx.mu.Unlock()
+	if err := db.checkContext(ctx, tx); err != nil {
+		return "", err
+	}
+	if value, deleted, ok := tx.ownWrite(key); ok {
+		return ownValue(value, deleted)
+	}
 
 	// 根據隔離級別獲取適當的讀鎖
-	if err := db.acquireLock(tx, key, ReadLock); err != nil {
+	if err := db.acquireLock(ctx, tx, key, ReadLock); err != nil {
 		return "", err
 	}
+	if tx.IsolationLevel == Serializable {
+		db.ssi.markRead(tx.ID, key)
+	}
 
-	db.mu.RLock()
-	record, exists := db.data[key]
+	record, exists := db.data.get(key)
 	if !exists {
-		db.mu.RUnlock()
 		return "", ErrKeyNotFound
 	}
-	db.mu.RUnlock()
 
 	// 根據隔離級別讀取適當的版本
 	version, err := record.GetVersion(tx.ReadTS, tx.IsolationLevel)
+	db.recordReadConflicts(tx, record, version)
 	if err != nil {
 		return "", err
 	}
 
-	// 記錄讀集
-	tx.ReadSet[key] = version.Timestamp
+	// 記錄讀集，讀未提交讀到的未提交版本沒有時間戳，不記錄
+	if !tx.ReadOnly && version.Committed {
+		tx.ReadSet[key] = version.Timestamp
+	}
 
+	if version.Deleted {
+		return "", ErrKeyNotFound
+	}
 	return version.Value, nil
 }
 
-// CleanupOldVersions 執行垃圾回收
-func (db *Database) CleanupOldVersions() {
-	db.mu.RLock()
-	oldestActiveTS := db.getOldestActiveTS()
-	records := make([]*Record, 0, len(db.data))
-	for _, record := range db.data {
-		records = append(records, record)
-	}
-	db.mu.RUnlock()
-
-	for _, record := range records {
-		record.CleanupVersions(oldestActiveTS)
-	}
+// CleanupOldVersions 執行垃圾回收，一次處理所有記錄，返回回收的版本數
+func (db *Database) CleanupOldVersions() int {
+	_, stats, _ := db.collectBatch("", 0)
+	return stats.Versions
 }
 
-// getOldestActiveTS 獲取最舊的活躍事務時間戳
+// getOldestActiveTS 獲取最舊的活躍事務或正在進行的檢查點的快照時間戳，調用者持有 db.mu
 func (db *Database) getOldestActiveTS() int {
 	db.txManager.mu.RLock()
 	defer db.txManager.mu.RUnlock()
 
 	oldestTS := db.currentTS
+	if db.pendingCheckpoint != nil {
+		oldestTS = db.pendingCheckpoint.ts
+	}
 	for _, tx := range db.txManager.activeTransactions {
 		if tx.ReadTS < oldestTS {
 			oldestTS = tx.ReadTS
@@ -167,11 +436,26 @@ func (db *Database) getOldestActiveTS() int {
 	return oldestTS
 }
 
-// GetData returns the internal data map for testing purposes
+// GetData returns a copy of the key -> record mapping for testing purposes
 func (db *Database) GetData() map[string]*Record {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
-	return db.data
+	data := make(map[string]*Record, db.data.len())
+	db.data.ascend("", func(key string, record *Record) bool {
+		data[key] = record
+		return true
+	})
+	return data
+}
+
+// Locks 返回當前鎖表的快照，見 LockManager.Locks
+func (db *Database) Locks() []LockInfo {
+	return db.lockManager.Locks()
+}
+
+// IsActive 報告事務是否仍然活躍；提交或回滾（包括被回收器回滾、作為死鎖犧牲者回滾）之後返回 false。
+// 不等待事務上進行中的操作
+func (db *Database) IsActive(tx *Transaction) bool {
+	_, err := db.txManager.GetTransaction(tx.ID)
+	return err == nil
 }
 
 // AdvanceTime advances the current timestamp
@@ -186,40 +470,96 @@ func (db *Database) Rollback(tx *Transaction) error {
 	if tx == nil {
 		return errors.New("invalid transaction")
 	}
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return db.rollback(tx)
+}
+
+// rollback 回滾事務，調用者需持有 tx.mu
+func (db *Database) rollback(tx *Transaction) error {
+	switch tx.Status {
+	case Committed:
+		return ErrInvalidTransaction
+	case Aborted:
+		return nil
+	}
 
 	db.mu.RLock()
 	defer db.mu.RUnlock()
 
 	// Undo changes for the keys in WriteSet
 	for key := range tx.WriteSet {
-		record := db.data[key]
-		record.mu.Lock()
-		newVersions := make([]*Version, 0)
-		for _, v := range record.versionChain.versions {
-			if v.Timestamp != tx.WriteTS {
-				newVersions = append(newVersions, v)
-			}
+		if record, exists := db.data.get(key); exists {
+			record.removeUncommitted(tx.ID)
 		}
-		record.versionChain.versions = newVersions
-		record.mu.Unlock()
-		// Release write lock for this key
-		db.lockManager.ReleaseLock(tx.ID, key)
 	}
 
-	// Release read locks for the keys in ReadSet
-	for key := range tx.ReadSet {
-		db.lockManager.ReleaseLock(tx.ID, key)
+	// 釋放讀寫鎖並取消仍在等待的鎖請求
+	db.lockManager.ReleaseAll(tx.ID)
+	if tx.IsolationLevel == Serializable {
+		db.ssi.abort(tx.ID)
 	}
 
+	tx.Status = Aborted
+	db.txManager.RemoveTransaction(tx.ID)
 	return nil
 }
 
+// needsLock 報告事務是否需要在鍵上持有 lockType 的鎖。
+// 可串行化事務由 SSI 檢測衝突，只讀事務不寫入，都不加鎖。
+// ReadCommitted 與 ReadUncommitted 只在寫入時加鎖：鎖衝突會阻塞等待，讀鎖又持有到事務結束，
+// 如果它們讀取時也加讀鎖，讀取會等待正在寫同一個鍵的事務提交，讀過的鍵也會阻塞其他事務的寫入，
+// 而這兩個級別本來就允許不可重複讀。讀到的鍵被並發修改時，ReadCommitted 改為在提交時由讀集驗證發現衝突。
+func needsLock(tx *Transaction, lockType LockType) bool {
+	if tx.IsolationLevel == Serializable || tx.ReadOnly {
+		return false
+	}
+	return lockType == WriteLock || tx.IsolationLevel >= RepeatableRead
+}
+
 // 增加2PL支持
-func (db *Database) acquireLock(tx *Transaction, key string, lockType LockType) error {
-	if tx.IsolationLevel == Serializable {
+func (db *Database) acquireLock(ctx context.Context, tx *Transaction, key string, lockType LockType) error {
+	if !needsLock(tx, lockType) {
 		return nil
 	}
-	return db.lockManager.AcquireLock(tx.ID, key, lockType)
+
+	timeout := tx.LockTimeout
+	if timeout <= 0 {
+		timeout = db.lockTimeout
+	}
+	waitCtx, unbind := tx.bind(ctx)
+	defer unbind()
+	waitCtx, cancel := context.WithTimeout(waitCtx, timeout)
+	defer cancel()
+	err := db.lockManager.AcquireLock(waitCtx, tx.ID, key, lockType)
+	if cerr := contextErr(ctx, tx); err != nil && cerr != nil {
+		// 等待被 ctx 或事務的上下文取消，而不是鎖等待超時
+		return db.abortContext(tx, cerr)
+	}
+	if errors.Is(err, ErrDeadlock) {
+		// 被選為死鎖犧牲者，回滾以釋放其他事務等待的鎖
+		db.rollback(tx)
+	}
+	if err == nil {
+		if held, exists := tx.locks[key]; !exists || held < lockType {
+			tx.locks[key] = lockType
+		}
+	}
+	return err
+}
+
+// enter 鎖定事務並驗證它仍然活躍，成功時調用者負責釋放 tx.mu。
+// 同一個事務上的操作因此依次執行，回收器也不會回滾正在執行操作的事務。
+func (db *Database) enter(tx *Transaction) error {
+	if tx == nil {
+		return ErrInvalidTransaction
+	}
+	tx.mu.Lock()
+	if err := db.validateTransaction(tx); err != nil {
+		tx.mu.Unlock()
+		return err
+	}
+	return nil
 }
 
 // 添加驗證事務的方法
@@ -227,58 +567,149 @@ func (db *Database) validateTransaction(tx *Transaction) error {
 	if tx == nil {
 		return ErrInvalidTransaction
 	}
-	_, err := db.txManager.GetTransaction(tx.ID)
-	return err
+	if _, err := db.txManager.GetTransaction(tx.ID); err != nil {
+		if tx.expired {
+			return ErrTransactionExpired
+		}
+		return err
+	}
+	return nil
 }
 
-// 添加驗證讀集的方法
-func (db *Database) validateReadSet(tx *Transaction, key string, ts int) bool {
-	record, exists := db.data[key]
+// hasWriteConflict 檢查是否有其他事務在 tx 的快照之後提交了 key 的版本，有時同時返回該版本的提交時間戳
+func (db *Database) hasWriteConflict(tx *Transaction, key string) (int, bool) {
+	record, exists := db.data.get(key)
 	if !exists {
-		return true
+		return 0, false
+	}
+	for _, v := range record.GetVersions() {
+		if v.Committed && v.TxID != tx.ID && v.Timestamp > tx.ReadTS {
+			return v.Timestamp, true
+		}
+	}
+	return 0, false
+}
+
+// 添加驗證讀集的方法，驗證失敗時同時返回較新版本的提交時間戳
+func (db *Database) validateReadSet(tx *Transaction, key string, ts int) (int, bool) {
+	record, exists := db.data.get(key)
+	if !exists {
+		return 0, true
 	}
 
 	versions := record.GetVersions()
 	for _, v := range versions {
 		if v.Timestamp > ts && v.Committed {
-			return false
+			return v.Timestamp, false
 		}
 	}
-	return true
+	return 0, true
 }
 
 // 添加 ReadWithIsolation 方法
 func (db *Database) ReadWithIsolation(tx *Transaction, key string, level IsolationLevel) (string, error) {
-	if err := db.validateTransaction(tx); err != nil {
+	if err := db.enter(tx); err != nil {
 		return "", err
 	}
+	defer tx.mu.Unlock()
+	if value, deleted, ok := tx.ownWrite(key); ok {
+		return ownValue(value, deleted)
+	}
 
-	db.mu.RLock()
-	record, exists := db.data[key]
+	if tx.IsolationLevel == Serializable {
+		db.ssi.markRead(tx.ID, key)
+	}
+
+	record, exists := db.data.get(key)
 	if !exists {
-		db.mu.RUnlock()
 		return "", ErrKeyNotFound
 	}
-	db.mu.RUnlock()
 
 	version, err := record.GetVersion(tx.ReadTS, level)
+	db.recordReadConflicts(tx, record, version)
 	if err != nil {
 		return "", err
 	}
 
-	tx.ReadSet[key] = version.Timestamp
+	if !tx.ReadOnly && version.Committed {
+		tx.ReadSet[key] = version.Timestamp
+	}
+	if version.Deleted {
+		return "", ErrKeyNotFound
+	}
 	return version.Value, nil
 }
 
+// visible 檢查 key 對事務是否存在：事務自己的寫入優先，否則看按隔離級別可見的版本是否為墓碑。
+// 可串行化事務的這次讀取與 Read 一樣登記 SIREAD 標記
+func (db *Database) visible(tx *Transaction, key string) bool {
+	if _, deleted, ok := tx.ownWrite(key); ok {
+		return !deleted
+	}
+	if tx.IsolationLevel == Serializable {
+		db.ssi.markRead(tx.ID, key)
+	}
+	record, exists := db.data.get(key)
+	if !exists {
+		return false
+	}
+	version, err := record.GetVersion(tx.ReadTS, tx.IsolationLevel)
+	db.recordReadConflicts(tx, record, version)
+	return err == nil && !version.Deleted
+}
+
+// restoreLock 將事務在 key 上的鎖恢復為獲取之前的狀態：之前沒有持有時釋放，之前持有讀鎖時降回讀鎖
+func (db *Database) restoreLock(tx *Transaction, key string, held LockType, holding bool) {
+	current, exists := tx.locks[key]
+	switch {
+	case !exists:
+	case !holding:
+		db.lockManager.ReleaseLock(tx.ID, key)
+		delete(tx.locks, key)
+	case held < current:
+		db.lockManager.Downgrade(tx.ID, key)
+		tx.locks[key] = held
+	}
+}
+
+// ownValue 事務讀到自己的寫入時返回的結果，不加鎖也不記錄讀集
+func ownValue(value string, deleted bool) (string, error) {
+	if deleted {
+		return "", ErrKeyNotFound
+	}
+	return value, nil
+}
+
+// recordReadConflicts 可串行化事務沒有看到比 version 更新的版本時，記錄指向這些寫者的反依賴
+func (db *Database) recordReadConflicts(tx *Transaction, record *Record, version *Version) {
+	if tx.IsolationLevel != Serializable {
+		return
+	}
+	db.ssi.readConflicts(tx.ID, record.newerWriters(version, tx.ID))
+}
+
+// CountRangeTx 在事務中計算範圍 [start, end] 內對該事務可見的鍵數量。
+// 可串行化事務會登記範圍讀取，之後其他事務插入範圍內的鍵會被檢測為衝突。
+func (db *Database) CountRangeTx(tx *Transaction, start, end string) (int, error) {
+	pairs, err := db.scan(context.Background(), tx, keyRange{start, end}, "", 0, false)
+	if err != nil {
+		return 0, err
+	}
+	return len(pairs), nil
+}
+
 // CountRange 計算範圍內的數據量
 func (db *Database) CountRange(start, end string) int {
-	db.mu.RLock()
-	defer db.mu.RUnlock()
 	count := 0
-	for key := range db.data {
-		if key >= start && key <= end {
+	db.data.ascend(start, func(key string, record *Record) bool {
+		if key > end {
+			return false
+		}
+		// 最新的已提交版本是墓碑時，key 已被刪除
+		if v, err := record.GetVersion(0, ReadCommitted); err == nil && !v.Deleted {
 			count++
 		}
-	}
+		return true
+	})
 	return count
 }
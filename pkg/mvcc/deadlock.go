@@ -0,0 +1,75 @@
+// 死鎖檢測
+package mvcc
+
+import "slices"
+
+// waitsFor 返回 txID 在等待圖中指向的事務：持有不相容鎖的事務，
+// 以及隊列中排在它前面且請求不相容的事務
+func (lm *LockManager) waitsFor(txID int) []int {
+	req, exists := lm.waiting[txID]
+	if !exists {
+		return nil
+	}
+	q := lm.locks[req.key]
+
+	var result []int
+	for tid, existingLock := range q.holders {
+		if tid != txID && (existingLock == WriteLock || req.lockType == WriteLock) {
+			result = append(result, tid)
+		}
+	}
+	for _, r := range q.waiters {
+		if r == req {
+			break
+		}
+		if r.txID != txID && (r.lockType == WriteLock || req.lockType == WriteLock) {
+			result = append(result, r.txID)
+		}
+	}
+	return result
+}
+
+// findCycle 在等待圖中尋找經過 start 的環，返回環上的事務
+func (lm *LockManager) findCycle(start int) []int {
+	visited := make(map[int]bool)
+	var path []int
+
+	var visit func(txID int) bool
+	visit = func(txID int) bool {
+		visited[txID] = true
+		path = append(path, txID)
+		for _, next := range lm.waitsFor(txID) {
+			if next == start {
+				return true
+			}
+			if !visited[next] && visit(next) {
+				return true
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+
+	if visit(start) {
+		return path
+	}
+	return nil
+}
+
+// resolveDeadlock 打破所有經過 txID 的環，每次選擇環上最年輕（ID 最大）的事務作為犧牲者。
+// 犧牲者的等待請求收到 Cause 為 ErrDeadlock 的 LockConflictError；返回值表示 txID 本身是否被選為犧牲者。
+func (lm *LockManager) resolveDeadlock(txID int) bool {
+	for {
+		cycle := lm.findCycle(txID)
+		if cycle == nil {
+			return false
+		}
+		victim := slices.Max(cycle)
+		if victim == txID {
+			return true
+		}
+		req := lm.waiting[victim]
+		req.ready <- lm.conflict(req, ErrDeadlock)
+		lm.removeWaiter(req)
+	}
+}
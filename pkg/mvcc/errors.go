@@ -1,10 +1,84 @@
 package mvcc
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+)
 
 var (
-    ErrVersionNotFound      = errors.New("version not found")
-    ErrKeyNotFound         = errors.New("key not found")
-    ErrSerializationFailure = errors.New("serialization failure")
-    ErrInvalidTransaction  = errors.New("invalid transaction")
-) 
\ No newline at end of file
+	ErrVersionNotFound      = errors.New("version not found")
+	ErrKeyNotFound          = errors.New("key not found")
+	ErrSerializationFailure = errors.New("serialization failure")
+	ErrInvalidTransaction   = errors.New("invalid transaction")
+	ErrNotPersistent        = errors.New("database is not persistent")
+	ErrLockTimeout          = errors.New("lock wait timeout")
+	ErrDeadlock             = errors.New("deadlock detected")
+	ErrWriteConflict        = errors.New("write conflict")
+	ErrInvalidEncoding      = errors.New("invalid encoded value")
+	ErrReadOnlyTransaction  = errors.New("cannot write in a read-only transaction")
+	ErrRangeLocked          = errors.New("range already locked")
+	ErrSavepointNotFound    = errors.New("savepoint not found")
+	ErrTransactionExpired   = errors.New("transaction expired")
+	ErrLockConflict         = errors.New("lock conflict")
+	ErrWALFailed            = errors.New("write-ahead log failed")
+)
+
+// LockConflictError 事務未能獲得鎖。Cause 是具體原因（ErrLockTimeout、ErrDeadlock 或 ErrRangeLocked），
+// errors.Is 對 ErrLockConflict 與 Cause 都成立。
+type LockConflictError struct {
+	TxID       int      // 請求鎖的事務，0 表示未知
+	Key        string   // 請求的鍵或範圍
+	HolderTxID int      // 阻塞請求的事務之一，0 表示未知
+	Requested  LockType // 請求的鎖類型
+	Cause      error
+}
+
+func (e *LockConflictError) Error() string {
+	msg := fmt.Sprintf("%v: %s lock on key %q", e.Cause, e.Requested, e.Key)
+	if e.TxID != 0 {
+		msg += fmt.Sprintf(" requested by transaction %d", e.TxID)
+	}
+	if e.HolderTxID != 0 {
+		msg += fmt.Sprintf(", blocked by transaction %d", e.HolderTxID)
+	}
+	return msg
+}
+
+func (e *LockConflictError) Is(target error) bool {
+	return target == ErrLockConflict
+}
+
+func (e *LockConflictError) Unwrap() error {
+	return e.Cause
+}
+
+// SerializationError 事務提交時驗證失敗。Cause 是 ErrWriteConflict 或 ErrSerializationFailure，
+// SSI 檢測到危險結構時 Key 為空。
+type SerializationError struct {
+	TxID          int
+	Key           string
+	ReadTS        int // 事務讀取時所見版本（或快照）的時間戳
+	ConflictingTS int // 在 ReadTS 之後提交的衝突版本的時間戳，0 表示未知
+	Cause         error
+}
+
+func (e *SerializationError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("%v: transaction %d (read ts %d)", e.Cause, e.TxID, e.ReadTS)
+	}
+	return fmt.Sprintf("%v: transaction %d read key %q at ts %d, conflicting commit at ts %d",
+		e.Cause, e.TxID, e.Key, e.ReadTS, e.ConflictingTS)
+}
+
+func (e *SerializationError) Unwrap() error {
+	return e.Cause
+}
+
+// IsRetryable 錯誤是否由並發衝突引起，在新事務中重新執行可能成功
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrSerializationFailure) ||
+		errors.Is(err, ErrWriteConflict) ||
+		errors.Is(err, ErrLockConflict) ||
+		errors.Is(err, ErrDeadlock) ||
+		errors.Is(err, ErrLockTimeout)
+}
@@ -0,0 +1,119 @@
+// 後台垃圾回收
+package mvcc
+
+import "time"
+
+// GCStats 一輪垃圾回收的結果
+type GCStats struct {
+	Records  int           // 檢查的記錄數
+	Versions int           // 回收的版本數，包括整個移除的記錄中剩下的版本
+	Removed  int           // 整個移除的記錄數
+	Duration time.Duration // 這一輪花費的時間
+}
+
+// runGC 按策略定期或在版本鏈過長時執行垃圾回收，直到數據庫關閉
+func (db *Database) runGC() {
+	defer db.background.Done()
+
+	var tick <-chan time.Time
+	if db.gcPolicy.Interval > 0 {
+		ticker := time.NewTicker(db.gcPolicy.Interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-tick:
+		case <-db.gcTrigger:
+		}
+		stats := db.gcCycle()
+		if db.gcPolicy.OnCycle != nil {
+			db.gcPolicy.OnCycle(stats)
+		}
+	}
+}
+
+// triggerGC 通知後台垃圾回收盡快運行一輪，已經有待處理的通知時不重複通知
+func (db *Database) triggerGC() {
+	select {
+	case db.gcTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// gcCycle 按鍵的順序分批處理所有記錄，數據庫關閉時提前結束
+func (db *Database) gcCycle() GCStats {
+	start := time.Now()
+	batch := db.gcPolicy.BatchSize
+	if batch < 1 {
+		batch = DefaultGCBatchSize
+	}
+
+	var total GCStats
+	from := ""
+	for {
+		next, stats, more := db.collectBatch(from, batch)
+		total.Records += stats.Records
+		total.Versions += stats.Versions
+		total.Removed += stats.Removed
+		if !more {
+			break
+		}
+		select {
+		case <-db.stop:
+			total.Duration = time.Since(start)
+			return total
+		default:
+		}
+		from = next
+	}
+	total.Duration = time.Since(start)
+	return total
+}
+
+// collectBatch 從 from 開始清理最多 limit 個記錄的過期版本（limit <= 0 表示不限制），
+// 返回下一批的起點，more 為 false 表示已經處理到最後一個記錄
+func (db *Database) collectBatch(from string, limit int) (next string, stats GCStats, more bool) {
+	db.mu.RLock()
+	oldestActiveTS := db.getOldestActiveTS()
+	db.mu.RUnlock()
+
+	keys := make([]string, 0)
+	records := make([]*Record, 0)
+	db.data.ascend(from, func(key string, record *Record) bool {
+		if limit > 0 && len(keys) == limit {
+			next, more = key, true
+			return false
+		}
+		keys = append(keys, key)
+		records = append(records, record)
+		return true
+	})
+	stats.Records = len(records)
+
+	dead := make([]string, 0)
+	for i, record := range records {
+		stats.Versions += record.CleanupVersions(oldestActiveTS)
+		if record.isDead(oldestActiveTS) {
+			dead = append(dead, keys[i])
+		}
+	}
+	if len(dead) == 0 {
+		return next, stats, more
+	}
+
+	// 只剩墓碑的記錄整個移除；持有 db.mu 的寫鎖時沒有寫入正在進行，需要再次確認
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for _, key := range dead {
+		if record, exists := db.data.get(key); exists && record.isDead(oldestActiveTS) {
+			stats.Versions += record.chainLength()
+			stats.Removed++
+			db.data.remove(key)
+		}
+	}
+	return next, stats, more
+}
@@ -0,0 +1,198 @@
+// 有序鍵索引（跳表）
+package mvcc
+
+import (
+	"math/rand/v2"
+	"sync"
+)
+
+const (
+	skipListMaxLevel = 24
+	skipListP        = 0.25
+)
+
+type skipNode struct {
+	key    string
+	record *Record
+	next   []*skipNode
+	prev   *skipNode // 最底層的前驅，用於反向遍歷
+}
+
+// keyIndex 以跳表按字典序保存 key -> Record，
+// 查找與插入為 O(log n)，範圍遍歷為 O(log n + k)
+type keyIndex struct {
+	mu     sync.RWMutex
+	head   *skipNode
+	tail   *skipNode // 最底層的最後一個節點
+	level  int
+	length int
+}
+
+func newKeyIndex() *keyIndex {
+	return &keyIndex{
+		head:  &skipNode{next: make([]*skipNode, skipListMaxLevel)},
+		level: 1,
+	}
+}
+
+func randomLevel() int {
+	level := 1
+	for level < skipListMaxLevel && rand.Float64() < skipListP {
+		level++
+	}
+	return level
+}
+
+// findGE 返回第一個 key >= target 的節點，update 記錄每層的前驅
+func (idx *keyIndex) findGE(target string, update []*skipNode) *skipNode {
+	node := idx.head
+	for i := idx.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].key < target {
+			node = node.next[i]
+		}
+		if update != nil {
+			update[i] = node
+		}
+	}
+	return node.next[0]
+}
+
+// findLE 返回最後一個 key <= target 的節點
+func (idx *keyIndex) findLE(target string) *skipNode {
+	node := idx.head
+	for i := idx.level - 1; i >= 0; i-- {
+		for node.next[i] != nil && node.next[i].key <= target {
+			node = node.next[i]
+		}
+	}
+	if node == idx.head {
+		return nil
+	}
+	return node
+}
+
+func (idx *keyIndex) get(key string) (*Record, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	node := idx.findGE(key, nil)
+	if node == nil || node.key != key {
+		return nil, false
+	}
+	return node.record, true
+}
+
+// getOrCreate 返回 key 對應的記錄，不存在時建立新記錄
+func (idx *keyIndex) getOrCreate(key string) *Record {
+	if record, exists := idx.get(key); exists {
+		return record
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	update := make([]*skipNode, skipListMaxLevel)
+	if node := idx.findGE(key, update); node != nil && node.key == key {
+		return node.record
+	}
+	record := NewRecord()
+	idx.insert(key, record, update)
+	return record
+}
+
+// put 設置 key 對應的記錄，已存在時覆蓋
+func (idx *keyIndex) put(key string, record *Record) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	update := make([]*skipNode, skipListMaxLevel)
+	if node := idx.findGE(key, update); node != nil && node.key == key {
+		node.record = record
+		return
+	}
+	idx.insert(key, record, update)
+}
+
+func (idx *keyIndex) insert(key string, record *Record, update []*skipNode) {
+	level := randomLevel()
+	if level > idx.level {
+		for i := idx.level; i < level; i++ {
+			update[i] = idx.head
+		}
+		idx.level = level
+	}
+
+	node := &skipNode{key: key, record: record, next: make([]*skipNode, level)}
+	for i := 0; i < level; i++ {
+		node.next[i] = update[i].next[i]
+		update[i].next[i] = node
+	}
+	if update[0] != idx.head {
+		node.prev = update[0]
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node
+	} else {
+		idx.tail = node
+	}
+	idx.length++
+}
+
+// remove 刪除 key，返回是否存在
+func (idx *keyIndex) remove(key string) bool {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	update := make([]*skipNode, skipListMaxLevel)
+	node := idx.findGE(key, update)
+	if node == nil || node.key != key {
+		return false
+	}
+	for i := 0; i < len(node.next); i++ {
+		update[i].next[i] = node.next[i]
+	}
+	if node.next[0] != nil {
+		node.next[0].prev = node.prev
+	} else {
+		idx.tail = node.prev
+	}
+	for idx.level > 1 && idx.head.next[idx.level-1] == nil {
+		idx.level--
+	}
+	idx.length--
+	return true
+}
+
+func (idx *keyIndex) len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return idx.length
+}
+
+// ascend 從第一個 key >= start 開始按升序遍歷，fn 返回 false 時停止。
+// 遍歷期間持有讀鎖，fn 不能修改索引。
+func (idx *keyIndex) ascend(start string, fn func(key string, record *Record) bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	for node := idx.findGE(start, nil); node != nil; node = node.next[0] {
+		if !fn(node.key, node.record) {
+			return
+		}
+	}
+}
+
+// descend 從最後一個 key <= end 開始按降序遍歷，end 為空表示從最大的 key 開始
+func (idx *keyIndex) descend(end string, fn func(key string, record *Record) bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	node := idx.tail
+	if end != "" {
+		node = idx.findLE(end)
+	}
+	for ; node != nil; node = node.prev {
+		if !fn(node.key, node.record) {
+			return
+		}
+	}
+}
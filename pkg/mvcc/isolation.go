@@ -1,20 +1,48 @@
 package mvcc
 
+import (
+	"fmt"
+	"strings"
+)
+
 // IsolationLevel 定義事務隔離級別
 type IsolationLevel int
 
 const (
-    ReadUncommitted IsolationLevel = iota
-    ReadCommitted
-    RepeatableRead
-    Serializable
+	ReadUncommitted IsolationLevel = iota
+	ReadCommitted
+	RepeatableRead
+	Serializable
 )
 
+var isolationNames = []string{"read_uncommitted", "read_committed", "repeatable_read", "serializable"}
+
+// 隔離級別的縮寫，與 isolationNames 一一對應
+var isolationAbbrevs = []string{"ru", "rc", "rr", "s"}
+
+func (l IsolationLevel) String() string {
+	if l < 0 || int(l) >= len(isolationNames) {
+		return fmt.Sprintf("IsolationLevel(%d)", int(l))
+	}
+	return isolationNames[l]
+}
+
+// ParseIsolationLevel 解析隔離級別的名稱（例如 "repeatable_read"、"repeatable-read"）或縮寫（"ru"、"rc"、"rr"、"s"），不區分大小寫
+func ParseIsolationLevel(s string) (IsolationLevel, error) {
+	name := strings.ReplaceAll(strings.ToLower(s), "-", "_")
+	for i := range isolationNames {
+		if name == isolationNames[i] || name == isolationAbbrevs[i] {
+			return IsolationLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown isolation level %q", s)
+}
+
 // 定義常見的並發異常
 type ConcurrencyError string
 
 const (
-    DirtyRead      ConcurrencyError = "dirty read"
-    NonRepeatableRead ConcurrencyError = "non-repeatable read"
-    PhantomRead    ConcurrencyError = "phantom read"
-)
\ No newline at end of file
+	DirtyRead         ConcurrencyError = "dirty read"
+	NonRepeatableRead ConcurrencyError = "non-repeatable read"
+	PhantomRead       ConcurrencyError = "phantom read"
+)
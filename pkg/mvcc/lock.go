@@ -1,58 +1,261 @@
 package mvcc
 
 import (
-    "errors"
-    "sync"
+	"context"
+	"errors"
+	"slices"
+	"sync"
 )
 
 type LockType int
 
 const (
-    ReadLock LockType = iota
-    WriteLock
+	ReadLock LockType = iota
+	WriteLock
 )
 
+func (t LockType) String() string {
+	if t == WriteLock {
+		return "write"
+	}
+	return "read"
+}
+
+// lockRequest 一個正在等待的鎖請求
+type lockRequest struct {
+	txID     int
+	key      string
+	lockType LockType
+	ready    chan error // 授予時收到 nil，等待被取消時收到錯誤
+}
+
+// lockQueue 單個鍵的持有者與先進先出的等待隊列
+type lockQueue struct {
+	holders map[int]LockType
+	waiters []*lockRequest
+}
+
 // LockManager 管理鎖
 type LockManager struct {
-    locks map[string]map[int]LockType  // key -> txID -> lockType
-    mu    sync.RWMutex
+	locks   map[string]*lockQueue       // key -> 持有者與等待隊列
+	held    map[int]map[string]struct{} // txID -> 持有鎖的key
+	waiting map[int]*lockRequest        // txID -> 正在等待的請求
+	mu      sync.Mutex
 }
 
 func NewLockManager() *LockManager {
-    return &LockManager{
-        locks: make(map[string]map[int]LockType),
-    }
+	return &LockManager{
+		locks:   make(map[string]*lockQueue),
+		held:    make(map[int]map[string]struct{}),
+		waiting: make(map[int]*lockRequest),
+	}
 }
 
-func (lm *LockManager) AcquireLock(txID int, key string, lockType LockType) error {
-    lm.mu.Lock()
-    defer lm.mu.Unlock()
+// AcquireLock 獲取鎖，有衝突時阻塞等待，直到持有者釋放鎖或 ctx 結束。
+// 等待者按到達順序授予，後到的讀鎖不會越過正在等待的寫鎖。
+func (lm *LockManager) AcquireLock(ctx context.Context, txID int, key string, lockType LockType) error {
+	lm.mu.Lock()
+
+	q, exists := lm.locks[key]
+	if !exists {
+		q = &lockQueue{holders: make(map[int]LockType)}
+		lm.locks[key] = q
+	}
+
+	held, holding := q.holders[txID]
+	if holding && (held == WriteLock || lockType == ReadLock) {
+		lm.mu.Unlock()
+		return nil
+	}
+
+	// 沒有人在等待時才能直接獲取；升級鎖不必排在其他等待者之後
+	if (holding || len(q.waiters) == 0) && q.compatible(txID, lockType) {
+		lm.grant(q, key, txID, lockType)
+		lm.mu.Unlock()
+		return nil
+	}
 
-    if _, exists := lm.locks[key]; !exists {
-        lm.locks[key] = make(map[int]LockType)
-    }
+	req := &lockRequest{txID: txID, key: key, lockType: lockType, ready: make(chan error, 1)}
+	if holding {
+		// 已持有讀鎖的事務升級為寫鎖時排在隊首，避免與後來者互相等待
+		q.waiters = append([]*lockRequest{req}, q.waiters...)
+	} else {
+		q.waiters = append(q.waiters, req)
+	}
+	lm.waiting[txID] = req
 
-    // 檢查鎖衝突
-    for tid, existingLock := range lm.locks[key] {
-        if tid != txID {
-            if existingLock == WriteLock || lockType == WriteLock {
-                return errors.New("lock conflict")
-            }
-        }
-    }
+	// 開始等待前檢查是否形成死鎖
+	if lm.resolveDeadlock(txID) {
+		err := lm.conflict(req, ErrDeadlock)
+		lm.removeWaiter(req)
+		lm.mu.Unlock()
+		return err
+	}
+	lm.mu.Unlock()
 
-    lm.locks[key][txID] = lockType
-    return nil
+	select {
+	case err := <-req.ready:
+		return err
+	case <-ctx.Done():
+	}
+
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	select {
+	case err := <-req.ready:
+		// 在超時的同時已經被授予或取消
+		return err
+	default:
+	}
+	err := lm.conflict(req, ErrLockTimeout)
+	lm.removeWaiter(req)
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return err
+	}
+	return ctx.Err()
+}
+
+// conflict 為仍在等待隊列中的請求構造 LockConflictError，HolderTxID 取阻塞它的事務中 ID 最小的一個
+func (lm *LockManager) conflict(req *lockRequest, cause error) *LockConflictError {
+	err := &LockConflictError{TxID: req.txID, Key: req.key, Requested: req.lockType, Cause: cause}
+	if blockers := lm.waitsFor(req.txID); len(blockers) > 0 {
+		err.HolderTxID = slices.Min(blockers)
+	}
+	return err
 }
 
 func (lm *LockManager) ReleaseLock(txID int, key string) {
-    lm.mu.Lock()
-    defer lm.mu.Unlock()
-
-    if keyLocks, exists := lm.locks[key]; exists {
-        delete(keyLocks, txID)
-        if len(keyLocks) == 0 {
-            delete(lm.locks, key)
-        }
-    }
-} 
\ No newline at end of file
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+	lm.release(txID, key)
+}
+
+// Downgrade 將事務持有的寫鎖降為讀鎖，並喚醒因此可以被授予的讀鎖請求
+func (lm *LockManager) Downgrade(txID int, key string) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	q, exists := lm.locks[key]
+	if !exists {
+		return
+	}
+	if held, holding := q.holders[txID]; holding && held == WriteLock {
+		q.holders[txID] = ReadLock
+		lm.grantWaiters(key, q)
+	}
+}
+
+// ReleaseAll 釋放事務持有的所有鎖，並取消它仍在等待的請求
+func (lm *LockManager) ReleaseAll(txID int) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	for key := range lm.held[txID] {
+		lm.release(txID, key)
+	}
+	if req, exists := lm.waiting[txID]; exists {
+		req.ready <- ErrInvalidTransaction
+		lm.removeWaiter(req)
+	}
+}
+
+// LockInfo 鎖表中的一項，是某個事務持有或正在等待的鎖
+type LockInfo struct {
+	Key     string
+	TxID    int
+	Type    LockType
+	Waiting bool // 正在等待而不是已經持有
+}
+
+// Locks 返回鎖表的快照，按鍵排序；同一個鍵先按事務 ID 列出持有者，再按隊列順序列出等待者
+func (lm *LockManager) Locks() []LockInfo {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	keys := make([]string, 0, len(lm.locks))
+	for key := range lm.locks {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var locks []LockInfo
+	for _, key := range keys {
+		q := lm.locks[key]
+		holders := make([]int, 0, len(q.holders))
+		for txID := range q.holders {
+			holders = append(holders, txID)
+		}
+		slices.Sort(holders)
+		for _, txID := range holders {
+			locks = append(locks, LockInfo{Key: key, TxID: txID, Type: q.holders[txID]})
+		}
+		for _, req := range q.waiters {
+			locks = append(locks, LockInfo{Key: key, TxID: req.txID, Type: req.lockType, Waiting: true})
+		}
+	}
+	return locks
+}
+
+// compatible 檢查 lockType 是否與其他事務持有的鎖相容
+func (q *lockQueue) compatible(txID int, lockType LockType) bool {
+	for tid, existingLock := range q.holders {
+		if tid != txID && (existingLock == WriteLock || lockType == WriteLock) {
+			return false
+		}
+	}
+	return true
+}
+
+func (lm *LockManager) grant(q *lockQueue, key string, txID int, lockType LockType) {
+	q.holders[txID] = lockType
+	if _, exists := lm.held[txID]; !exists {
+		lm.held[txID] = make(map[string]struct{})
+	}
+	lm.held[txID][key] = struct{}{}
+}
+
+func (lm *LockManager) release(txID int, key string) {
+	if q, exists := lm.locks[key]; exists {
+		delete(q.holders, txID)
+		lm.grantWaiters(key, q)
+	}
+	if keys, exists := lm.held[txID]; exists {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(lm.held, txID)
+		}
+	}
+}
+
+// removeWaiter 將請求移出等待隊列，隊首變化後可能有其他請求可以被授予
+func (lm *LockManager) removeWaiter(req *lockRequest) {
+	delete(lm.waiting, req.txID)
+	q, exists := lm.locks[req.key]
+	if !exists {
+		return
+	}
+	for i, r := range q.waiters {
+		if r == req {
+			q.waiters = append(q.waiters[:i], q.waiters[i+1:]...)
+			break
+		}
+	}
+	lm.grantWaiters(req.key, q)
+}
+
+// grantWaiters 按順序授予隊首的請求，遇到第一個不相容的請求即停止
+func (lm *LockManager) grantWaiters(key string, q *lockQueue) {
+	for len(q.waiters) > 0 {
+		req := q.waiters[0]
+		if !q.compatible(req.txID, req.lockType) {
+			break
+		}
+		q.waiters = q.waiters[1:]
+		delete(lm.waiting, req.txID)
+		lm.grant(q, key, req.txID, req.lockType)
+		req.ready <- nil
+	}
+	if len(q.holders) == 0 && len(q.waiters) == 0 {
+		delete(lm.locks, key)
+	}
+}
@@ -0,0 +1,74 @@
+package mvcc
+
+import "time"
+
+// DefaultLockTimeout 默認的鎖等待時間
+const DefaultLockTimeout = 5 * time.Second
+
+// DefaultRetryPolicy Update 默認的重試策略
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	Backoff:     time.Millisecond,
+	MaxBackoff:  100 * time.Millisecond,
+}
+
+// RetryPolicy 配置 Update 遇到可重試錯誤時如何重新執行事務
+type RetryPolicy struct {
+	MaxAttempts int           // 最多執行的次數（包括第一次），小於 1 時視為 1
+	Backoff     time.Duration // 第一次重試前的等待時間，之後每次加倍
+	MaxBackoff  time.Duration // 等待時間的上限，0 表示不限制
+}
+
+// DefaultGCBatchSize 後台垃圾回收每批默認處理的記錄數
+const DefaultGCBatchSize = 256
+
+// GCPolicy 配置後台垃圾回收。Interval 與 MaxChainLength 都為 0 時不啟動後台回收。
+type GCPolicy struct {
+	Interval       time.Duration // 定期回收的間隔，0 表示不定期回收
+	MaxChainLength int           // 提交後有版本鏈長度超過它時立即回收，0 表示不按長度觸發
+	BatchSize      int           // 每批處理的記錄數，批次之間釋放鎖以縮短停頓，小於 1 時使用 DefaultGCBatchSize
+	OnCycle        func(GCStats) // 每輪回收結束後調用，可以為 nil
+}
+
+func (p GCPolicy) enabled() bool {
+	return p.Interval > 0 || p.MaxChainLength > 0
+}
+
+// Option 配置數據庫
+type Option func(*Database)
+
+// WithLockTimeout 設置等待鎖的最長時間，事務可以通過 Transaction.LockTimeout 單獨覆蓋
+func WithLockTimeout(d time.Duration) Option {
+	return func(db *Database) {
+		db.lockTimeout = d
+	}
+}
+
+// WithMaxTransactionLifetime 設置事務的最長存活時間，超過後事務會被後台回收器回滾並釋放鎖，
+// 之後對它的操作返回 ErrTransactionExpired。回收器在 Close 時停止。
+func WithMaxTransactionLifetime(d time.Duration) Option {
+	return func(db *Database) {
+		db.maxTxLife = d
+	}
+}
+
+// WithGC 啟動按 p 運行的後台垃圾回收，它在 Close 時停止
+func WithGC(p GCPolicy) Option {
+	return func(db *Database) {
+		db.gcPolicy = p
+	}
+}
+
+// WithRetryPolicy 設置 Update 與 View 的重試策略
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(db *Database) {
+		db.retryPolicy = p
+	}
+}
+
+// WithWALFile 在 Open 打開或建立日誌段時用 wrap 包裝文件，主要用於測試中注入寫入或同步失敗
+func WithWALFile(wrap func(WALFile) WALFile) Option {
+	return func(db *Database) {
+		db.walFile = wrap
+	}
+}
@@ -0,0 +1,36 @@
+// 回收超時的事務
+package mvcc
+
+import "time"
+
+// reapExpired 定期回滾存活時間超過 maxTxLife 的事務，直到數據庫關閉。
+// 每半個存活時間檢查一次，事務最晚在到期後半個存活時間內被回滾。
+// 存活時間只有 1ns 時半個存活時間為零，NewTicker 會 panic，因此間隔至少為 1ns。
+func (db *Database) reapExpired() {
+	defer db.background.Done()
+
+	ticker := time.NewTicker(max(db.maxTxLife/2, time.Nanosecond))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-db.stop:
+			return
+		case now := <-ticker.C:
+			db.reap(now)
+		}
+	}
+}
+
+// reap 回滾在 now 之前已經到期的事務。正在執行操作（例如等待鎖）的事務不是被遺棄的，留到下一輪再檢查。
+func (db *Database) reap(now time.Time) {
+	for _, tx := range db.txManager.startedBefore(now.Add(-db.maxTxLife)) {
+		if !tx.mu.TryLock() {
+			continue
+		}
+		if tx.Status == Active {
+			tx.expired = true
+			db.rollback(tx)
+		}
+		tx.mu.Unlock()
+	}
+}
@@ -15,16 +15,27 @@ func NewRecord() *Record {
 	}
 }
 
-func (r *Record) InsertVersion(value string, ts int, txID int) error {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-
-	version := &Version{
+// InsertVersion 插入 txID 的未提交版本，提交時才分配時間戳；txID 已有未提交版本時覆蓋它
+func (r *Record) InsertVersion(value string, txID int) error {
+	return r.insert(&Version{
 		Value:     value,
-		Timestamp: ts,
 		TxID:      txID,
 		Committed: false,
-	}
+	})
+}
+
+// InsertTombstone 插入刪除標記，之後的讀取會看到 key 不存在
+func (r *Record) InsertTombstone(txID int) error {
+	return r.insert(&Version{
+		TxID:      txID,
+		Committed: false,
+		Deleted:   true,
+	})
+}
+
+func (r *Record) insert(version *Version) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
 	r.versionChain.AddVersion(version)
 	return nil
@@ -36,24 +47,105 @@ func (r *Record) GetVersion(ts int, isolationLevel IsolationLevel) (*Version, er
 	return r.versionChain.GetVersion(ts, isolationLevel)
 }
 
-func (r *Record) CommitVersion(txID int) error {
+// CommitVersion 以提交時間戳 commitTS 提交 txID 寫入的版本
+func (r *Record) CommitVersion(txID int, commitTS int) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	return r.versionChain.CommitVersion(txID, commitTS)
+}
 
-	versions := r.versionChain.GetVersions()
-	for _, v := range versions {
-		if v.TxID == txID && !v.Committed {
-			v.Committed = true
-			return nil
+// removeUncommitted 移除 txID 寫入的未提交版本
+func (r *Record) removeUncommitted(txID int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	vc := r.versionChain
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+	versions := make([]*Version, 0, len(vc.versions))
+	for _, v := range vc.versions {
+		if v.Committed || v.TxID != txID {
+			versions = append(versions, v)
 		}
 	}
-	return ErrVersionNotFound
+	vc.versions = versions
+}
+
+// restoreUncommitted 將 txID 的未提交版本恢復為 value，deleted 時恢復為墓碑
+func (r *Record) restoreUncommitted(txID int, value string, deleted bool) {
+	r.insert(&Version{
+		Value:     value,
+		TxID:      txID,
+		Committed: false,
+		Deleted:   deleted,
+	})
 }
 
-func (r *Record) CleanupVersions(oldestActiveTS int) {
+// CleanupVersions 清理過期版本，返回移除的版本數
+func (r *Record) CleanupVersions(oldestActiveTS int) int {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.versionChain.CleanupVersions(oldestActiveTS)
+	return r.versionChain.CleanupVersions(oldestActiveTS)
+}
+
+// chainLength 版本鏈的長度
+func (r *Record) chainLength() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.versionChain.GetVersions())
+}
+
+// isDead 記錄是否已經可以從數據庫中移除：沒有任何版本，
+// 或只剩下一個所有活躍事務都能看到的已提交墓碑
+func (r *Record) isDead(oldestActiveTS int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.versionChain.GetVersions()
+	switch len(versions) {
+	case 0:
+		return true
+	case 1:
+		v := versions[0]
+		return v.Deleted && v.Committed && v.Timestamp <= oldestActiveTS
+	}
+	return false
+}
+
+// hasUncommitted 報告 txID 在記錄上是否有未提交的版本
+func (r *Record) hasUncommitted(txID int) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, v := range r.versionChain.GetVersions() {
+		if !v.Committed && v.TxID == txID {
+			return true
+		}
+	}
+	return false
+}
+
+// newerWriters 返回版本鏈中排在 v 之後、由其他事務寫入的版本的事務ID；v 為 nil 時返回所有寫者。
+// 版本在提交時被副本取代，因此按寫入的事務而不是指針找到 v，每個事務在版本鏈上只有一個版本。
+func (r *Record) newerWriters(v *Version, txID int) []int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	versions := r.versionChain.GetVersions()
+	start := 0
+	for i, existing := range versions {
+		if v != nil && existing.TxID == v.TxID {
+			start = i + 1
+			break
+		}
+	}
+
+	var writers []int
+	for _, existing := range versions[start:] {
+		if existing.TxID != txID {
+			writers = append(writers, existing.TxID)
+		}
+	}
+	return writers
 }
 
 // GetVersions returns all versions (for testing)
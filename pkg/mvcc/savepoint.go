@@ -0,0 +1,97 @@
+// 保存點與部分回滾
+package mvcc
+
+import "maps"
+
+// undoEntry 一次寫入之前 key 在事務中的狀態
+type undoEntry struct {
+	key     string
+	existed bool // 事務之前是否已經寫過 key
+	value   string
+	deleted bool
+}
+
+// savepoint 記錄建立時的寫入日誌長度與持有的鎖
+type savepoint struct {
+	name  string
+	undo  int
+	locks map[string]LockType
+}
+
+// Savepoint 在事務當前的位置建立名為 name 的保存點，之後可以用 Database.RollbackTo 撤銷其後的寫入。
+// 同名的保存點可以重複建立，回滾時使用最近的一個。
+func (tx *Transaction) Savepoint(name string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.savepoints = append(tx.savepoints, savepoint{
+		name:  name,
+		undo:  len(tx.undo),
+		locks: maps.Clone(tx.locks),
+	})
+}
+
+// logWrite 在寫入 key 之前記錄事務中 key 原來的狀態
+func (tx *Transaction) logWrite(key string) {
+	value, existed := tx.WriteSet[key]
+	_, deleted := tx.DeleteSet[key]
+	tx.undo = append(tx.undo, undoEntry{key: key, existed: existed, value: value, deleted: deleted})
+}
+
+// RollbackTo 撤銷事務在保存點 name 之後的寫入，同一個 key 被寫過多次時恢復為保存點時的值，
+// 並釋放保存點之後獲得的鎖。保存點本身保留，之後建立的保存點被丟棄。
+// 保存點之後的讀取仍留在讀集中，提交時照常驗證。
+func (db *Database) RollbackTo(tx *Transaction, name string) error {
+	if err := db.enter(tx); err != nil {
+		return err
+	}
+	defer tx.mu.Unlock()
+
+	i := len(tx.savepoints) - 1
+	for i >= 0 && tx.savepoints[i].name != name {
+		i--
+	}
+	if i < 0 {
+		return ErrSavepointNotFound
+	}
+	sp := tx.savepoints[i]
+
+	db.mu.RLock()
+	for j := len(tx.undo) - 1; j >= sp.undo; j-- {
+		e := tx.undo[j]
+		record, exists := db.data.get(e.key)
+		if !e.existed {
+			delete(tx.WriteSet, e.key)
+			delete(tx.DeleteSet, e.key)
+			if exists {
+				record.removeUncommitted(tx.ID)
+			}
+			continue
+		}
+
+		tx.WriteSet[e.key] = e.value
+		if e.deleted {
+			tx.DeleteSet[e.key] = struct{}{}
+		} else {
+			delete(tx.DeleteSet, e.key)
+		}
+		if exists {
+			record.restoreUncommitted(tx.ID, e.value, e.deleted)
+		}
+	}
+	db.mu.RUnlock()
+
+	// 保存點之後才獲得的鎖全部釋放，之後才升級的寫鎖降回讀鎖
+	for key, lockType := range tx.locks {
+		held, ok := sp.locks[key]
+		if !ok {
+			db.lockManager.ReleaseLock(tx.ID, key)
+		} else if held < lockType {
+			db.lockManager.Downgrade(tx.ID, key)
+		}
+	}
+
+	tx.locks = maps.Clone(sp.locks)
+	tx.undo = tx.undo[:sp.undo]
+	tx.savepoints = tx.savepoints[:i+1]
+	return nil
+}
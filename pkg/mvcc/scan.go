@@ -0,0 +1,156 @@
+// 範圍掃描
+package mvcc
+
+import (
+	"context"
+	"strings"
+)
+
+// KeyValue 掃描結果中的一個鍵值對
+type KeyValue struct {
+	Key   string
+	Value string
+}
+
+// Iterator 按掃描順序遍歷鍵值對
+//
+//	it, err := db.Scan(tx, "a", "z", 0)
+//	for it.Next() {
+//		fmt.Println(it.Key(), it.Value())
+//	}
+type Iterator struct {
+	pairs []KeyValue
+	pos   int
+}
+
+// Next 移動到下一個鍵值對，沒有更多結果時返回 false
+func (it *Iterator) Next() bool {
+	if it.pos >= len(it.pairs) {
+		return false
+	}
+	it.pos++
+	return true
+}
+
+// Key 當前的鍵
+func (it *Iterator) Key() string {
+	return it.pairs[it.pos-1].Key
+}
+
+// Value 當前的值
+func (it *Iterator) Value() string {
+	return it.pairs[it.pos-1].Value
+}
+
+// Len 掃描結果的總數
+func (it *Iterator) Len() int {
+	return len(it.pairs)
+}
+
+// Scan 按鍵的升序掃描 [start, end] 內對事務可見的鍵值對。
+// end 為空字串表示沒有上界，limit <= 0 表示不限制數量。
+func (db *Database) Scan(tx *Transaction, start, end string, limit int) (*Iterator, error) {
+	return db.ScanCtx(context.Background(), tx, start, end, limit)
+}
+
+// ScanCtx 與 Scan 相同，ctx 結束時放棄等待讀鎖並回滾事務
+func (db *Database) ScanCtx(ctx context.Context, tx *Transaction, start, end string, limit int) (*Iterator, error) {
+	pairs, err := db.scan(ctx, tx, keyRange{start, end}, "", limit, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Iterator{pairs: pairs}, nil
+}
+
+// ReverseScan 與 Scan 相同，但按鍵的降序返回
+func (db *Database) ReverseScan(tx *Transaction, start, end string, limit int) (*Iterator, error) {
+	return db.ReverseScanCtx(context.Background(), tx, start, end, limit)
+}
+
+// ReverseScanCtx 與 ScanCtx 相同，但按鍵的降序返回
+func (db *Database) ReverseScanCtx(ctx context.Context, tx *Transaction, start, end string, limit int) (*Iterator, error) {
+	pairs, err := db.scan(ctx, tx, keyRange{start, end}, "", limit, true)
+	if err != nil {
+		return nil, err
+	}
+	return &Iterator{pairs: pairs}, nil
+}
+
+// PrefixScan 按升序掃描以 prefix 開頭的鍵
+func (db *Database) PrefixScan(tx *Transaction, prefix string, limit int) (*Iterator, error) {
+	pairs, err := db.scan(context.Background(), tx, keyRange{prefix, prefixEnd(prefix)}, prefix, limit, false)
+	if err != nil {
+		return nil, err
+	}
+	return &Iterator{pairs: pairs}, nil
+}
+
+// prefixEnd 返回所有以 prefix 開頭的鍵的上界（包含該上界本身），沒有上界時返回空字串
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return ""
+}
+
+// scan 依照事務的隔離級別與 ReadTS 讀取範圍內每個鍵的可見版本，事務自己寫過的鍵返回自己的寫入，
+// 讀到的其他鍵記錄到讀集；可串行化事務同時登記整個範圍的 SIREAD 標記
+func (db *Database) scan(ctx context.Context, tx *Transaction, r keyRange, prefix string, limit int, reverse bool) ([]KeyValue, error) {
+	if err := db.enter(tx); err != nil {
+		return nil, err
+	}
+	defer tx.mu.Unlock()
+	if err := db.checkContext(ctx, tx); err != nil {
+		return nil, err
+	}
+	if tx.IsolationLevel == Serializable {
+		db.ssi.markRangeRead(tx.ID, r.start, r.end)
+	}
+
+	// 在索引中按順序找出可見的版本，遍歷期間不等待鎖
+	pairs := make([]KeyValue, 0)
+	versions := make([]*Version, 0)
+	visit := func(key string, record *Record) bool {
+		if !r.contains(key) || !strings.HasPrefix(key, prefix) {
+			return false
+		}
+		// 事務自己寫過的鍵返回自己的寫入
+		if value, deleted, ok := tx.ownWrite(key); ok {
+			if !deleted {
+				pairs = append(pairs, KeyValue{Key: key, Value: value})
+				versions = append(versions, nil)
+			}
+			return limit <= 0 || len(pairs) < limit
+		}
+		version, err := record.GetVersion(tx.ReadTS, tx.IsolationLevel)
+		db.recordReadConflicts(tx, record, version)
+		if err == nil && !version.Deleted {
+			pairs = append(pairs, KeyValue{Key: key, Value: version.Value})
+			versions = append(versions, version)
+		}
+		return limit <= 0 || len(pairs) < limit
+	}
+	if reverse {
+		db.data.descend(r.end, visit)
+	} else {
+		db.data.ascend(r.start, visit)
+	}
+
+	// 只對可見的鍵加讀鎖，避免等待範圍內其他事務尚未提交的插入
+	for i, pair := range pairs {
+		if versions[i] == nil {
+			continue
+		}
+		if err := db.acquireLock(ctx, tx, pair.Key, ReadLock); err != nil {
+			return nil, err
+		}
+		if !tx.ReadOnly && versions[i].Committed {
+			tx.ReadSet[pair.Key] = versions[i].Timestamp
+		}
+	}
+	return pairs, nil
+}
@@ -1,7 +1,6 @@
 package mvcc
 
 import (
-	"errors"
 	"sync"
 )
 
@@ -22,7 +21,7 @@ func (s *SerializableLock) LockRange(key string) error {
 	defer s.mu.Unlock()
 
 	if _, exists := s.locks[key]; exists {
-		return errors.New("range already locked")
+		return &LockConflictError{Key: key, Requested: WriteLock, Cause: ErrRangeLocked}
 	}
 	s.locks[key] = struct{}{}
 	return nil
@@ -0,0 +1,212 @@
+// 可串行化快照隔離 (Serializable Snapshot Isolation)
+package mvcc
+
+import "sync"
+
+// keyRange 閉區間 [start, end]，end 為空表示沒有上界
+type keyRange struct {
+	start string
+	end   string
+}
+
+func (r keyRange) contains(key string) bool {
+	return key >= r.start && (r.end == "" || key <= r.end)
+}
+
+// ssiTxn 一個可串行化事務的讀寫反依賴 (rw-antidependency)
+type ssiTxn struct {
+	id        int
+	startSeq  int
+	commitSeq int // 0 表示尚未提交
+	aborted   bool
+	in        map[int]struct{} // 這些並發事務讀到的數據被本事務覆蓋: in -> 本事務
+	out       map[int]struct{} // 本事務讀到的數據被這些並發事務覆蓋: 本事務 -> out
+	keys      []string         // 本事務的 SIREAD 鍵標記
+}
+
+// ssiManager 追蹤 SIREAD 標記並在提交時檢測危險結構：
+// 一個事務同時有 rw 反依賴指入與指出時 (T_in -> pivot -> T_out)，可能形成不可串行化的調度
+type ssiManager struct {
+	mu      sync.Mutex
+	seq     int // 開始與提交的先後順序
+	txns    map[int]*ssiTxn
+	readers map[string]map[int]struct{} // key -> 讀取過該鍵的事務
+	ranges  map[int][]keyRange          // txID -> 讀取過的範圍
+}
+
+func newSSIManager() *ssiManager {
+	return &ssiManager{
+		txns:    make(map[int]*ssiTxn),
+		readers: make(map[string]map[int]struct{}),
+		ranges:  make(map[int][]keyRange),
+	}
+}
+
+func (m *ssiManager) begin(txID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.seq++
+	m.txns[txID] = &ssiTxn{
+		id:       txID,
+		startSeq: m.seq,
+		in:       make(map[int]struct{}),
+		out:      make(map[int]struct{}),
+	}
+}
+
+// markRead 在讀取版本之前登記 SIREAD 鍵標記
+func (m *ssiManager) markRead(txID int, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, exists := m.txns[txID]
+	if !exists {
+		return
+	}
+	if _, exists := m.readers[key]; !exists {
+		m.readers[key] = make(map[int]struct{})
+	}
+	if _, exists := m.readers[key][txID]; !exists {
+		m.readers[key][txID] = struct{}{}
+		t.keys = append(t.keys, key)
+	}
+}
+
+// markRangeRead 在掃描範圍之前登記 SIREAD 範圍標記，之後插入範圍內的寫入也會形成衝突
+func (m *ssiManager) markRangeRead(txID int, start, end string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.txns[txID]; exists {
+		m.ranges[txID] = append(m.ranges[txID], keyRange{start, end})
+	}
+}
+
+// readConflicts 讀者沒有看到 writers 寫入的版本，記錄 reader -> writer
+func (m *ssiManager) readConflicts(readerID int, writers []int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, writerID := range writers {
+		m.addConflict(readerID, writerID)
+	}
+}
+
+// onWrite 寫入後調用，持有該鍵或覆蓋該鍵範圍 SIREAD 標記的並發事務指向寫者
+func (m *ssiManager) onWrite(writerID int, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for readerID := range m.readers[key] {
+		m.addConflict(readerID, writerID)
+	}
+	for readerID, ranges := range m.ranges {
+		for _, r := range ranges {
+			if r.contains(key) {
+				m.addConflict(readerID, writerID)
+				break
+			}
+		}
+	}
+}
+
+func (m *ssiManager) addConflict(readerID, writerID int) {
+	if readerID == writerID {
+		return
+	}
+	reader, ok1 := m.txns[readerID]
+	writer, ok2 := m.txns[writerID]
+	if !ok1 || !ok2 || reader.aborted || writer.aborted || !concurrent(reader, writer) {
+		return
+	}
+	reader.out[writerID] = struct{}{}
+	writer.in[readerID] = struct{}{}
+}
+
+// concurrent 兩個事務的生命週期是否重疊
+func concurrent(a, b *ssiTxn) bool {
+	if a.commitSeq != 0 && a.commitSeq < b.startSeq {
+		return false
+	}
+	if b.commitSeq != 0 && b.commitSeq < a.startSeq {
+		return false
+	}
+	return true
+}
+
+// commit 檢測危險結構，沒有問題時將事務標記為已提交
+func (m *ssiManager) commit(txID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, exists := m.txns[txID]
+	if !exists {
+		return nil
+	}
+
+	// 本事務是樞紐
+	if m.hasLive(t.in) && m.hasLive(t.out) {
+		return ErrSerializationFailure
+	}
+	// 已提交的事務是樞紐，本事務是它的 T_out 或 T_in
+	for id := range t.in {
+		if pivot, ok := m.txns[id]; ok && !pivot.aborted && pivot.commitSeq != 0 && m.hasLive(pivot.in) {
+			return ErrSerializationFailure
+		}
+	}
+	for id := range t.out {
+		if pivot, ok := m.txns[id]; ok && !pivot.aborted && pivot.commitSeq != 0 && m.hasLive(pivot.out) {
+			return ErrSerializationFailure
+		}
+	}
+
+	m.seq++
+	t.commitSeq = m.seq
+	m.prune()
+	return nil
+}
+
+// abort 將事務標記為已中止，它參與的反依賴不再有效
+func (m *ssiManager) abort(txID int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if t, exists := m.txns[txID]; exists {
+		t.aborted = true
+		m.prune()
+	}
+}
+
+// hasLive 集合中是否有未中止的事務
+func (m *ssiManager) hasLive(ids map[int]struct{}) bool {
+	for id := range ids {
+		if t, ok := m.txns[id]; ok && !t.aborted {
+			return true
+		}
+	}
+	return false
+}
+
+// prune 清理不再與任何活躍事務並發的事務及其 SIREAD 標記
+func (m *ssiManager) prune() {
+	oldestActive := 0
+	for _, t := range m.txns {
+		if t.commitSeq == 0 && !t.aborted && (oldestActive == 0 || t.startSeq < oldestActive) {
+			oldestActive = t.startSeq
+		}
+	}
+
+	for id, t := range m.txns {
+		if t.aborted || (t.commitSeq != 0 && (oldestActive == 0 || t.commitSeq < oldestActive)) {
+			for _, key := range t.keys {
+				delete(m.readers[key], id)
+				if len(m.readers[key]) == 0 {
+					delete(m.readers, key)
+				}
+			}
+			delete(m.ranges, id)
+			delete(m.txns, id)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// 帶類型的值存取
+package mvcc
+
+// Store 通過 Codec 在 Database 上讀寫類型為 V 的值。
+// Database 以字串保存任意字節，Store 只負責編解碼，事務與隔離語義與 Database 相同。
+//
+//	counters := mvcc.NewStore[int64](db, mvcc.Int64Codec{})
+//	n, err := counters.Read(tx, "visits")
+//	err = counters.Write(tx, "visits", n+1)
+type Store[V any] struct {
+	db    *Database
+	codec Codec[V]
+}
+
+// NewStore 創建使用 codec 編解碼的 Store
+func NewStore[V any](db *Database, codec Codec[V]) *Store[V] {
+	return &Store[V]{db: db, codec: codec}
+}
+
+// DB 返回底層的數據庫
+func (s *Store[V]) DB() *Database {
+	return s.db
+}
+
+// Write 編碼後寫入
+func (s *Store[V]) Write(tx *Transaction, key string, value V) error {
+	data, err := s.codec.Encode(value)
+	if err != nil {
+		return err
+	}
+	return s.db.Write(tx, key, string(data))
+}
+
+// Read 按事務的隔離級別讀取並解碼
+func (s *Store[V]) Read(tx *Transaction, key string) (V, error) {
+	return s.decode(s.db.Read(tx, key))
+}
+
+// ReadWithIsolation 按指定的隔離級別讀取並解碼
+func (s *Store[V]) ReadWithIsolation(tx *Transaction, key string, level IsolationLevel) (V, error) {
+	return s.decode(s.db.ReadWithIsolation(tx, key, level))
+}
+
+// Delete 刪除 key
+func (s *Store[V]) Delete(tx *Transaction, key string) error {
+	return s.db.Delete(tx, key)
+}
+
+// Value 解碼迭代器當前的值
+func (s *Store[V]) Value(it *Iterator) (V, error) {
+	return s.decode(it.Value(), nil)
+}
+
+func (s *Store[V]) decode(data string, err error) (V, error) {
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return s.codec.Decode([]byte(data))
+}
@@ -1,13 +1,30 @@
 package mvcc
 
+import (
+	"context"
+	"sync"
+	"time"
+)
+
 type Transaction struct {
 	ID             int
-	ReadTS         int
-	WriteTS        int
+	ReadTS         int // 快照時間戳：只能看到提交時間戳不大於它的版本
+	WriteTS        int // 提交時間戳，提交時才分配
 	IsolationLevel IsolationLevel
-	ReadSet        map[string]int    // 記錄讀取的key和版本
-	WriteSet       map[string]string // 記錄寫入的key和值
+	ReadSet        map[string]int      // 記錄讀取的key和版本
+	WriteSet       map[string]string   // 記錄寫入的key和值
+	DeleteSet      map[string]struct{} // 記錄刪除的key，這些key在WriteSet中的值為空
 	Status         TransactionStatus
+	LockTimeout    time.Duration // 等待鎖的最長時間，0 表示使用數據庫的默認值
+	ReadOnly       bool          // 只讀事務不加鎖、不記錄讀集，提交時不做驗證
+	StartTime      time.Time     // 事務開始的時間，超過數據庫的最長存活時間後會被回收器回滾
+
+	mu         sync.Mutex          // 同一事務上的操作依次執行
+	expired    bool                // 被回收器回滾，之後的操作返回 ErrTransactionExpired
+	ctx        context.Context     // BeginTx 傳入的上下文，結束後事務會在下一次操作時回滾
+	locks      map[string]LockType // 已獲得的鎖，用於回滾到保存點時釋放之後獲得的鎖
+	undo       []undoEntry         // 按寫入順序記錄每次寫入之前的狀態
+	savepoints []savepoint
 }
 
 type TransactionStatus int
@@ -18,14 +35,30 @@ const (
 	Aborted
 )
 
-func NewTransaction(id int, level IsolationLevel) *Transaction {
+func NewTransaction(id int, readTS int, level IsolationLevel) *Transaction {
 	return &Transaction{
 		ID:             id,
-		ReadTS:         id,
-		WriteTS:        id,
+		ReadTS:         readTS,
 		IsolationLevel: level,
 		ReadSet:        make(map[string]int),
 		WriteSet:       make(map[string]string),
+		DeleteSet:      make(map[string]struct{}),
+		locks:          make(map[string]LockType),
+		StartTime:      time.Now(),
 		Status:         Active,
 	}
 }
+
+// State 返回事務的狀態與提交時間戳。與事務上的操作互斥，有操作（例如等待鎖）進行中時等待它結束
+func (tx *Transaction) State() (TransactionStatus, int) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	return tx.Status, tx.WriteTS
+}
+
+// ownWrite 返回事務自己對 key 的寫入，ok 為 false 表示事務沒有寫過 key
+func (tx *Transaction) ownWrite(key string) (value string, deleted, ok bool) {
+	value, ok = tx.WriteSet[key]
+	_, deleted = tx.DeleteSet[key]
+	return value, deleted, ok
+}
@@ -1,5 +1,7 @@
 package mvcc
 
+import "time"
+
 func NewTransactionManager() *TransactionManager {
 	return &TransactionManager{
 		activeTransactions: make(map[int]*Transaction),
@@ -27,3 +29,16 @@ func (tm *TransactionManager) GetTransaction(txID int) (*Transaction, error) {
 	}
 	return tx, nil
 }
+
+// startedBefore 返回在 t 之前開始、仍然活躍的事務
+func (tm *TransactionManager) startedBefore(t time.Time) []*Transaction {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	var txs []*Transaction
+	for _, tx := range tm.activeTransactions {
+		if tx.StartTime.Before(t) {
+			txs = append(txs, tx)
+		}
+	}
+	return txs
+}
@@ -0,0 +1,62 @@
+// 閉包式事務
+package mvcc
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Update 在 level 隔離級別的事務中執行 fn：fn 返回 nil 時提交，返回錯誤或 panic 時回滾，
+// panic 會在回滾後繼續向上拋出。
+// 事務因序列化失敗、寫寫衝突、死鎖或鎖等待超時而失敗時，按重試策略在新事務中重新執行 fn，
+// 因此 fn 可能被調用多次，不應該有事務以外的副作用。
+//
+//	err := db.Update(mvcc.Serializable, func(tx *mvcc.Transaction) error {
+//		return db.Write(tx, "key1", "value1")
+//	})
+func (db *Database) Update(level IsolationLevel, fn func(tx *Transaction) error) error {
+	return db.retry(func() error {
+		return db.runTx(db.Begin(level), fn)
+	})
+}
+
+// View 在只讀事務中執行 fn，fn 看到的是開始時的一致快照，不加鎖也不會因衝突而失敗，
+// 因此不需要重試；fn 中的寫入返回 ErrReadOnlyTransaction。
+func (db *Database) View(fn func(tx *Transaction) error) error {
+	return db.runTx(db.BeginReadOnly(), fn)
+}
+
+func (db *Database) runTx(tx *Transaction, fn func(tx *Transaction) error) error {
+	defer func() {
+		if p := recover(); p != nil {
+			db.Rollback(tx)
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		db.Rollback(tx)
+		return err
+	}
+	return db.Commit(tx)
+}
+
+// retry 執行 run，遇到可重試的錯誤時等待後重新執行，等待時間按指數增長並加入隨機抖動
+func (db *Database) retry(run func() error) error {
+	policy := db.retryPolicy
+	backoff := policy.Backoff
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || !IsRetryable(err) || attempt >= policy.MaxAttempts {
+			return err
+		}
+
+		if backoff > 0 {
+			time.Sleep(backoff/2 + rand.N(backoff/2+1))
+			backoff *= 2
+			if policy.MaxBackoff > 0 && backoff > policy.MaxBackoff {
+				backoff = policy.MaxBackoff
+			}
+		}
+	}
+}
@@ -1,16 +1,18 @@
 package mvcc
 
 import (
+	"slices"
 	"sync"
 )
 
 // Version 定義數據版本
 type Version struct {
 	Value     string
-	Timestamp int  // 創建時間戳
-	EndTS     int  // 結束時間戳，0表示當前有效
+	Timestamp int  // 提交時間戳，未提交的版本為 0
+	EndTS     int  // 被下一個已提交版本取代的時間戳，0表示當前有效
 	Committed bool // 是否已提交
 	TxID      int  // 創建該版本的事務ID
+	Deleted   bool // 是否為刪除標記（墓碑）
 }
 
 // VersionChain 管理版本鏈
@@ -25,18 +27,56 @@ func NewVersionChain() *VersionChain {
 	}
 }
 
-// AddVersion 添加新版本
+// AddVersion 添加新版本。同一事務已經有未提交的版本時用 v 取代它，
+// 因此每個事務在一個版本鏈上最多只有一個未提交版本，提交後也只產生一個已提交版本。
+// 已經加入版本鏈的版本可能正在被讀取，因此只替換而不修改。
 func (vc *VersionChain) AddVersion(v *Version) {
 	vc.mu.Lock()
 	defer vc.mu.Unlock()
 
-	if len(vc.versions) > 0 {
-		// 設置前一個版本的結束時間戳
-		vc.versions[len(vc.versions)-1].EndTS = v.Timestamp
+	if !v.Committed {
+		for i, existing := range vc.versions {
+			if !existing.Committed && existing.TxID == v.TxID {
+				vc.versions[i] = v
+				return
+			}
+		}
 	}
 	vc.versions = append(vc.versions, v)
 }
 
+// CommitVersion 以提交時間戳提交 txID 的未提交版本。
+// 已提交的版本按提交時間戳排在未提交版本之前，提交時將版本移到已提交部分的末尾。
+func (vc *VersionChain) CommitVersion(txID, commitTS int) error {
+	vc.mu.Lock()
+	defer vc.mu.Unlock()
+
+	pos, last := -1, -1
+	for i, v := range vc.versions {
+		if v.Committed {
+			last = i
+		} else if v.TxID == txID && pos == -1 {
+			pos = i
+		}
+	}
+	if pos == -1 {
+		return ErrVersionNotFound
+	}
+
+	// 以副本提交並設置前一個已提交版本的結束時間戳，不修改讀者可能持有的版本
+	v := *vc.versions[pos]
+	v.Timestamp = commitTS
+	v.Committed = true
+	if last >= 0 {
+		prev := *vc.versions[last]
+		prev.EndTS = commitTS
+		vc.versions[last] = &prev
+	}
+	copy(vc.versions[last+2:pos+1], vc.versions[last+1:pos])
+	vc.versions[last+1] = &v
+	return nil
+}
+
 // GetVersion 根據時間戳獲取對應版本
 func (vc *VersionChain) GetVersion(ts int, isolationLevel IsolationLevel) (*Version, error) {
 	vc.mu.RLock()
@@ -68,26 +108,27 @@ func (vc *VersionChain) GetVersion(ts int, isolationLevel IsolationLevel) (*Vers
 	return nil, ErrVersionNotFound
 }
 
-// CleanupVersions 清理過期版本
-func (vc *VersionChain) CleanupVersions(oldestActiveTS int) {
+// CleanupVersions 清理過期版本，返回移除的版本數
+func (vc *VersionChain) CleanupVersions(oldestActiveTS int) int {
 	vc.mu.Lock()
 	defer vc.mu.Unlock()
 
 	if len(vc.versions) <= 1 {
-		return
+		return 0
 	}
 
 	// 找到最後一個需要保留的版本索引
 	keepIndex := 0
 	for i := len(vc.versions) - 1; i >= 0; i-- {
 		v := vc.versions[i]
-		if v.Timestamp < oldestActiveTS && v.Committed {
+		// 所有活躍事務都能看到這個版本，更舊的版本不再需要
+		if v.Timestamp <= oldestActiveTS && v.Committed {
 			keepIndex = i
 			break
 		}
 	}
 
-	// 至少保留一個已提交的版本
+	// 至少保留一個已提交的版本；只剩墓碑的記錄由 Database 整個移除
 	if keepIndex > 0 {
 		// 確保保留的是已提交的版本
 		hasCommitted := false
@@ -100,13 +141,15 @@ func (vc *VersionChain) CleanupVersions(oldestActiveTS int) {
 
 		if hasCommitted {
 			vc.versions = vc.versions[keepIndex:]
+			return keepIndex
 		}
 	}
+	return 0
 }
 
-// GetVersions 獲取所有版本（用於測試）
+// GetVersions 獲取所有版本的副本（用於測試），之後版本鏈的變化不影響返回的切片
 func (vc *VersionChain) GetVersions() []*Version {
 	vc.mu.RLock()
 	defer vc.mu.RUnlock()
-	return vc.versions
+	return slices.Clone(vc.versions)
 }
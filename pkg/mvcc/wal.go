@@ -0,0 +1,368 @@
+// 預寫日誌 (write-ahead log)
+package mvcc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/utils"
+)
+
+// 日誌按段存放，檔名中的序號遞增: wal-0000000000000001.log
+const walSegmentPattern = "wal-%016d.log"
+
+// 每筆日誌記錄的頭部: 4 bytes 長度 + 4 bytes CRC32
+const walHeaderSize = 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var errCorruptEntry = errors.New("corrupt wal entry")
+
+type walOp byte
+
+const (
+	walOpPut walOp = iota + 1
+	walOpDelete
+)
+
+// walWrite 一個鍵的寫入
+type walWrite struct {
+	op    walOp
+	key   string
+	value string
+}
+
+// walEntry 一個已提交事務的日誌記錄
+type walEntry struct {
+	txID   int
+	ts     int
+	writes []walWrite
+}
+
+// WALFile 日誌段的文件操作，由 *os.File 實現
+type WALFile interface {
+	io.Writer
+	Sync() error
+	Truncate(size int64) error
+	Seek(offset int64, whence int) (int64, error)
+	Close() error
+}
+
+// wal 僅追加的分段日誌，每次追加後都會 fsync
+type wal struct {
+	mu     sync.Mutex
+	dir    string
+	seq    int // 當前寫入的段序號
+	file   WALFile
+	size   int64                 // 當前段中已經完整寫入並同步的長度
+	wrap   func(WALFile) WALFile // 包裝打開的日誌段，可以為 nil
+	failed error                 // 寫入失敗後未能截斷日誌，之後的追加都被拒絕
+}
+
+// openWAL 依序讀出序號不小於 fromSeq 的所有日誌段，並打開最後一段繼續追加。
+// 最後一段尾部不完整或校驗失敗的記錄（例如提交途中崩潰）會被截斷。
+func openWAL(dir string, fromSeq int, wrap func(WALFile) WALFile) (*wal, []walEntry, error) {
+	seqs, err := listSeqs(dir, walSegmentPattern)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entries []walEntry
+	w := &wal{dir: dir, seq: fromSeq, wrap: wrap}
+	for i, seq := range seqs {
+		if seq < fromSeq {
+			continue
+		}
+		last := i == len(seqs)-1
+		file, err := os.OpenFile(segmentPath(dir, seq), os.O_RDWR, 0o644)
+		if err != nil {
+			return nil, nil, err
+		}
+		segEntries, validSize, err := readWALEntries(file)
+		if err == nil && !last {
+			// 只有最後一段可能因為崩潰而不完整
+			var info os.FileInfo
+			if info, err = file.Stat(); err == nil && info.Size() != validSize {
+				err = fmt.Errorf("wal segment %d: %w", seq, errCorruptEntry)
+			}
+		}
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		entries = append(entries, segEntries...)
+
+		if !last {
+			file.Close()
+			continue
+		}
+		if err := file.Truncate(validSize); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		if _, err := file.Seek(validSize, io.SeekStart); err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		w.seq, w.file, w.size = seq, w.wrapFile(file), validSize
+	}
+
+	if w.file == nil {
+		file, err := createSegment(dir, w.seq)
+		if err != nil {
+			return nil, nil, err
+		}
+		w.file = w.wrapFile(file)
+	}
+	return w, entries, nil
+}
+
+func (w *wal) wrapFile(file *os.File) WALFile {
+	if w.wrap == nil {
+		return file
+	}
+	return w.wrap(file)
+}
+
+// readWALEntries 依序讀取記錄，返回記錄與最後一筆完整記錄的結束位置
+func readWALEntries(r io.Reader) ([]walEntry, int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var entries []walEntry
+	offset := 0
+	for {
+		payload, next, ok := readFrame(data, offset)
+		if !ok {
+			break
+		}
+		entry, err := decodeWALEntry(payload)
+		if err != nil {
+			break
+		}
+		entries = append(entries, entry)
+		offset = next
+	}
+	return entries, int64(offset), nil
+}
+
+// readFrame 讀取 offset 處的一個帶長度與校驗碼的記錄
+func readFrame(data []byte, offset int) ([]byte, int, bool) {
+	if len(data)-offset < walHeaderSize {
+		return nil, offset, false
+	}
+	size := int(binary.LittleEndian.Uint32(data[offset:]))
+	sum := binary.LittleEndian.Uint32(data[offset+4:])
+	start := offset + walHeaderSize
+	if size > len(data)-start {
+		return nil, offset, false
+	}
+	payload := data[start : start+size]
+	if crc32.Checksum(payload, crcTable) != sum {
+		return nil, offset, false
+	}
+	return payload, start + size, true
+}
+
+// appendFrame 為 payload 加上長度與校驗碼
+func appendFrame(buf, payload []byte) []byte {
+	var header [walHeaderSize]byte
+	binary.LittleEndian.PutUint32(header[:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[4:], crc32.Checksum(payload, crcTable))
+	buf = append(buf, header[:]...)
+	return append(buf, payload...)
+}
+
+// append 寫入一筆記錄並同步到磁碟。失敗時將日誌截斷回寫入之前的長度，
+// 不留下不完整的記錄使之後的提交在恢復時丟失，也不讓被回滾的事務在重啟後出現；
+// 截斷也失敗時日誌進入失敗狀態，之後的追加都返回 ErrWALFailed。
+func (w *wal) append(e *walEntry) error {
+	buf := appendFrame(nil, encodeWALEntry(e))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.failed != nil {
+		return fmt.Errorf("%w: %v", ErrWALFailed, w.failed)
+	}
+	_, err := w.file.Write(buf)
+	if err == nil {
+		err = w.file.Sync()
+	}
+	if err != nil {
+		if terr := w.truncate(w.size); terr != nil {
+			w.failed = terr
+			return fmt.Errorf("%w: %w (truncating after failed append: %v)", ErrWALFailed, err, terr)
+		}
+		return err
+	}
+	w.size += int64(len(buf))
+	return nil
+}
+
+// truncate 丟棄當前段中 size 之後的內容並同步到磁碟
+func (w *wal) truncate(size int64) error {
+	if err := w.file.Truncate(size); err != nil {
+		return err
+	}
+	if _, err := w.file.Seek(size, io.SeekStart); err != nil {
+		return err
+	}
+	return w.file.Sync()
+}
+
+// rotate 封存當前段並開始寫入新段，返回新段的序號
+func (w *wal) rotate() (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	file, err := createSegment(w.dir, w.seq+1)
+	if err != nil {
+		return 0, err
+	}
+	if err := w.file.Close(); err != nil {
+		file.Close()
+		return 0, err
+	}
+	w.seq++
+	w.file, w.size = w.wrapFile(file), 0
+	return w.seq, nil
+}
+
+// removeBefore 刪除序號小於 seq 的日誌段
+func (w *wal) removeBefore(seq int) error {
+	seqs, err := listSeqs(w.dir, walSegmentPattern)
+	if err != nil {
+		return err
+	}
+	for _, s := range seqs {
+		if s >= seq {
+			break
+		}
+		if err := os.Remove(segmentPath(w.dir, s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *wal) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
+
+func encodeWALEntry(e *walEntry) []byte {
+	buf := utils.AppendUvarint(nil, e.txID)
+	buf = utils.AppendUvarint(buf, e.ts)
+	buf = utils.AppendUvarint(buf, len(e.writes))
+	for _, w := range e.writes {
+		buf = append(buf, byte(w.op))
+		buf = utils.AppendString(buf, w.key)
+		buf = utils.AppendString(buf, w.value)
+	}
+	return buf
+}
+
+func decodeWALEntry(buf []byte) (walEntry, error) {
+	var e walEntry
+	var n int
+	var err error
+	if e.txID, buf, err = utils.ReadUvarint(buf); err != nil {
+		return e, err
+	}
+	if e.ts, buf, err = utils.ReadUvarint(buf); err != nil {
+		return e, err
+	}
+	if n, buf, err = utils.ReadUvarint(buf); err != nil {
+		return e, err
+	}
+	for i := 0; i < n; i++ {
+		if len(buf) == 0 {
+			return e, errCorruptEntry
+		}
+		w := walWrite{op: walOp(buf[0])}
+		if w.op != walOpPut && w.op != walOpDelete {
+			return e, errCorruptEntry
+		}
+		if w.key, buf, err = utils.ReadString(buf[1:]); err != nil {
+			return e, err
+		}
+		if w.value, buf, err = utils.ReadString(buf); err != nil {
+			return e, err
+		}
+		e.writes = append(e.writes, w)
+	}
+	return e, nil
+}
+
+// newWALEntry 根據事務的寫集建立日誌記錄，鍵按字典序排列
+func newWALEntry(tx *Transaction) *walEntry {
+	keys := make([]string, 0, len(tx.WriteSet))
+	for key := range tx.WriteSet {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	e := &walEntry{txID: tx.ID, ts: tx.WriteTS}
+	for _, key := range keys {
+		op := walOpPut
+		if _, deleted := tx.DeleteSet[key]; deleted {
+			op = walOpDelete
+		}
+		e.writes = append(e.writes, walWrite{op: op, key: key, value: tx.WriteSet[key]})
+	}
+	return e
+}
+
+func segmentPath(dir string, seq int) string {
+	return filepath.Join(dir, fmt.Sprintf(walSegmentPattern, seq))
+}
+
+// createSegment 建立新的日誌段並同步目錄，確保檔案本身在崩潰後仍然存在
+func createSegment(dir string, seq int) (*os.File, error) {
+	file, err := os.OpenFile(segmentPath(dir, seq), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncDir(dir); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// listSeqs 列出目錄中符合檔名模式的序號，由小到大排列
+func listSeqs(dir, pattern string) ([]int, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	var seqs []int
+	for _, name := range names {
+		var seq int
+		base := filepath.Base(name)
+		if _, err := fmt.Sscanf(base, pattern, &seq); err == nil && fmt.Sprintf(pattern, seq) == base {
+			seqs = append(seqs, seq)
+		}
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
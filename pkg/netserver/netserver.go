@@ -0,0 +1,94 @@
+// TCP 服務端共用的接受連線與連線管理
+package netserver
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+var ErrServerClosed = errors.New("server closed")
+
+// Server 接受連線並在各自的 goroutine 中以 handle 處理，handle 返回後關閉連線。
+// Close 斷開所有連線並等待 handle 返回，協議相關的清理（例如回滾事務）放在 handle 中即可在 Close 返回前完成。
+type Server struct {
+	handle func(net.Conn)
+
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func New(handle func(conn net.Conn)) *Server {
+	return &Server{
+		handle:    handle,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve 在 l 上接受連線直到 Close 被調用，此時返回 ErrServerClosed
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrServerClosed
+	}
+	s.listeners[l] = struct{}{}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			delete(s.listeners, l)
+			s.mu.Unlock()
+			if closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+		s.mu.Unlock()
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		conn.Close()
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+	}()
+	s.handle(conn)
+}
+
+// Close 停止接受連線，斷開所有連線並等待它們的處理結束
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return nil
+}
@@ -0,0 +1,94 @@
+// SCAN MATCH 使用的 Redis 風格通配符
+package resp
+
+// globMatch 按 Redis 的規則匹配 pattern：* 匹配任意多個字節（包括 "/"，與 path.Match 不同），
+// ? 匹配一個字節，[abc]、[a-z]、[^a] 匹配字符集，\ 轉義下一個字符。
+func globMatch(pattern, s string) bool {
+	p, i := 0, 0
+	// 最近一個 * 的位置與它當時對應的 s 位置，失配時讓這個 * 多吞一個字節再試
+	star, mark := -1, 0
+	for i < len(s) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				star, mark = p, i
+				p++
+				continue
+			case '?':
+				p++
+				i++
+				continue
+			case '[':
+				if ok, next := matchClass(pattern, p, s[i]); ok {
+					p = next
+					i++
+					continue
+				}
+			case '\\':
+				if p+1 < len(pattern) && pattern[p+1] == s[i] {
+					p += 2
+					i++
+					continue
+				}
+				if p+1 == len(pattern) && s[i] == '\\' {
+					p++
+					i++
+					continue
+				}
+			default:
+				if pattern[p] == s[i] {
+					p++
+					i++
+					continue
+				}
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		mark++
+		p, i = star+1, mark
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+// matchClass 匹配 pattern[p] 開始的字符集，返回是否匹配以及字符集之後的位置。
+// 與 Redis 相同，沒有閉合的 [ 延伸到 pattern 結尾。
+func matchClass(pattern string, p int, c byte) (bool, int) {
+	p++
+	negate := p < len(pattern) && pattern[p] == '^'
+	if negate {
+		p++
+	}
+	match := false
+	for p < len(pattern) && pattern[p] != ']' {
+		switch {
+		case pattern[p] == '\\' && p+1 < len(pattern):
+			p++
+			if pattern[p] == c {
+				match = true
+			}
+		case p+2 < len(pattern) && pattern[p+1] == '-' && pattern[p+2] != ']':
+			lo, hi := pattern[p], pattern[p+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if lo <= c && c <= hi {
+				match = true
+			}
+			p += 2
+		default:
+			if pattern[p] == c {
+				match = true
+			}
+		}
+		p++
+	}
+	if p < len(pattern) {
+		p++ // 跳過 ]
+	}
+	return match != negate, p
+}
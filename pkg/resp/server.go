@@ -0,0 +1,401 @@
+// 兼容 Redis 協議的前端
+package resp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/netserver"
+)
+
+var ErrServerClosed = netserver.ErrServerClosed
+
+// 命令需要的事務
+type txKind int
+
+const (
+	noTx    txKind = iota // 不訪問數據
+	readTx                // 自動提交時在只讀事務中執行
+	writeTx               // 自動提交時在可重試的讀寫事務中執行
+)
+
+// command 可以在 MULTI 中排隊的命令；arity 為參數個數（包括命令名），負數表示至少 -arity 個
+type command struct {
+	arity int
+	kind  txKind
+}
+
+var commands = map[string]command{
+	"ping": {-1, noTx},
+	"echo": {2, noTx},
+	"get":  {2, readTx},
+	"scan": {-2, readTx},
+	"set":  {3, writeTx},
+	"del":  {-2, writeTx},
+}
+
+// 回應的類型，由 writeReply 按 RESP 編碼；string 為批量字串，nil 為空批量字串
+type (
+	status     string
+	errorReply string
+)
+
+// Server 以 Redis 協議提供 mvcc.Database。
+// GET/SET/DEL/SCAN 在 MULTI 之外各自在自動提交的事務中執行；MULTI/EXEC 之間的命令在同一個事務中執行，
+// EXEC 時任何命令因數據庫錯誤失敗都會回滾整個事務。WATCH 開始一個 ReadCommitted 事務並讀取被監視的鍵，
+// 提交時由讀集驗證檢測它們是否被修改，被修改時 EXEC 返回空陣列。
+type Server struct {
+	*netserver.Server // Serve 與 Close
+
+	db *mvcc.Database
+}
+
+func New(db *mvcc.Database) *Server {
+	s := &Server{db: db}
+	s.Server = netserver.New(s.serveConn)
+	return s
+}
+
+// session 一個連線的 MULTI/WATCH 狀態
+type session struct {
+	multi   bool
+	queue   [][]string
+	dirty   bool              // MULTI 期間有命令排隊失敗，EXEC 時放棄事務
+	watch   *mvcc.Transaction // WATCH 開始的事務
+	watched map[string]int    // 被監視的鍵與 WATCH 時讀到的版本時間戳
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	sess := &session{}
+	defer s.unwatch(sess)
+
+	r := bufio.NewReader(conn)
+	w := writer{bufio.NewWriter(conn)}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if errors.Is(err, errProtocol) {
+				w.error("ERR " + err.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := s.dispatch(sess, w, args)
+		// 客戶端一次發送多個命令時，處理完緩衝區中的命令才寫出回應
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// dispatch 執行一個命令並寫出回應，返回 true 表示關閉連線
+func (s *Server) dispatch(sess *session, w writer, args []string) bool {
+	name := strings.ToLower(args[0])
+	switch name {
+	case "quit":
+		w.simple("OK")
+		return true
+	case "command":
+		// redis-cli 啟動時查詢命令文檔，返回空列表即可
+		w.array(0)
+	case "multi":
+		if sess.multi {
+			w.error("ERR MULTI calls can not be nested")
+			break
+		}
+		sess.multi = true
+		w.simple("OK")
+	case "exec":
+		if !sess.multi {
+			w.error("ERR EXEC without MULTI")
+			break
+		}
+		writeReply(w, s.execMulti(sess))
+	case "discard":
+		if !sess.multi {
+			w.error("ERR DISCARD without MULTI")
+			break
+		}
+		s.reset(sess)
+		w.simple("OK")
+	case "watch":
+		if sess.multi {
+			w.error("ERR WATCH inside MULTI is not allowed")
+			break
+		}
+		if len(args) < 2 {
+			w.error(arityError(name))
+			break
+		}
+		writeReply(w, s.watchKeys(sess, args[1:]))
+	case "unwatch":
+		s.unwatch(sess)
+		w.simple("OK")
+	default:
+		cmd, exists := commands[name]
+		var reply any
+		switch {
+		case !exists:
+			reply = errorReply(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		case !checkArity(cmd, args):
+			reply = errorReply(arityError(name))
+		case sess.multi:
+			sess.queue = append(sess.queue, args)
+			reply = status("QUEUED")
+		default:
+			reply = s.autoCommit(cmd, args)
+		}
+		if _, failed := reply.(errorReply); failed && sess.multi {
+			sess.dirty = true
+		}
+		writeReply(w, reply)
+	}
+	return false
+}
+
+func checkArity(cmd command, args []string) bool {
+	if cmd.arity < 0 {
+		return len(args) >= -cmd.arity
+	}
+	return len(args) == cmd.arity
+}
+
+func arityError(name string) string {
+	return fmt.Sprintf("ERR wrong number of arguments for '%s' command", name)
+}
+
+// autoCommit 在單獨的事務中執行一個命令
+func (s *Server) autoCommit(cmd command, args []string) any {
+	var reply any
+	run := func(tx *mvcc.Transaction) (err error) {
+		reply, err = s.exec(tx, args)
+		return err
+	}
+
+	var err error
+	switch cmd.kind {
+	case noTx:
+		reply, err = s.exec(nil, args)
+	case readTx:
+		err = s.db.View(run)
+	case writeTx:
+		err = s.db.Update(mvcc.RepeatableRead, run)
+	}
+	if err != nil {
+		return dbError(err)
+	}
+	return reply
+}
+
+// execMulti 在一個事務中執行排隊的命令
+func (s *Server) execMulti(sess *session) any {
+	queue, dirty, watch, watched := sess.queue, sess.dirty, sess.watch, sess.watched
+	sess.watch = nil
+	s.reset(sess)
+	if dirty {
+		if watch != nil {
+			s.db.Rollback(watch)
+		}
+		return errorReply("EXECABORT Transaction discarded because of previous errors.")
+	}
+
+	var replies []any
+	run := func(tx *mvcc.Transaction) error {
+		replies = make([]any, 0, len(queue))
+		for _, args := range queue {
+			reply, err := s.exec(tx, args)
+			if err != nil {
+				return err
+			}
+			replies = append(replies, reply)
+		}
+		return nil
+	}
+
+	if watch == nil {
+		// 沒有監視的鍵時，因衝突失敗的事務可以安全地重試
+		if err := s.db.Update(mvcc.RepeatableRead, run); err != nil {
+			return dbError(err)
+		}
+		return replies
+	}
+
+	if err := run(watch); err != nil {
+		s.db.Rollback(watch)
+		return dbError(err)
+	}
+	// EXEC 中再次讀取被監視的鍵會更新讀集，恢復為 WATCH 時的版本以檢測期間的修改
+	for key, ts := range watched {
+		watch.ReadSet[key] = ts
+	}
+	if err := s.db.Commit(watch); err != nil {
+		if errors.Is(err, mvcc.ErrSerializationFailure) {
+			// 被監視的鍵在 WATCH 之後被修改
+			return []any(nil)
+		}
+		return dbError(err)
+	}
+	return replies
+}
+
+// watchKeys 在 WATCH 的事務中讀取 keys，讓提交時的讀集驗證檢查它們。
+// 讀取時不存在的鍵以時間戳 0 記錄，之後被建立也會使驗證失敗。
+func (s *Server) watchKeys(sess *session, keys []string) any {
+	if sess.watch == nil {
+		sess.watch = s.db.Begin(mvcc.ReadCommitted)
+		sess.watched = make(map[string]int)
+	}
+	for _, key := range keys {
+		_, err := s.db.Read(sess.watch, key)
+		if err != nil && !errors.Is(err, mvcc.ErrKeyNotFound) && !errors.Is(err, mvcc.ErrVersionNotFound) {
+			s.unwatch(sess)
+			return dbError(err)
+		}
+		if _, seen := sess.watched[key]; !seen {
+			sess.watched[key] = sess.watch.ReadSet[key]
+		}
+	}
+	return status("OK")
+}
+
+func (s *Server) unwatch(sess *session) {
+	if sess.watch != nil {
+		s.db.Rollback(sess.watch)
+		sess.watch, sess.watched = nil, nil
+	}
+}
+
+// reset 結束 MULTI 並取消監視
+func (s *Server) reset(sess *session) {
+	sess.multi, sess.queue, sess.dirty = false, nil, false
+	s.unwatch(sess)
+}
+
+// exec 在 tx 中執行一個數據命令。參數錯誤作為 errorReply 返回，
+// 數據庫錯誤作為 error 返回，調用者據此回滾事務。
+func (s *Server) exec(tx *mvcc.Transaction, args []string) (any, error) {
+	switch strings.ToLower(args[0]) {
+	case "ping":
+		if len(args) > 2 {
+			return errorReply(arityError("ping")), nil
+		}
+		if len(args) == 2 {
+			return args[1], nil
+		}
+		return status("PONG"), nil
+	case "echo":
+		return args[1], nil
+	case "get":
+		value, err := s.db.Read(tx, args[1])
+		if errors.Is(err, mvcc.ErrKeyNotFound) || errors.Is(err, mvcc.ErrVersionNotFound) {
+			return nil, nil
+		}
+		return value, err
+	case "set":
+		if err := s.db.Write(tx, args[1], args[2]); err != nil {
+			return nil, err
+		}
+		return status("OK"), nil
+	case "del":
+		deleted := 0
+		for _, key := range args[1:] {
+			// 直接刪除而不是先讀：讀鎖升級為寫鎖時，兩個事務同時 DEL 同一個鍵會互相等待
+			err := s.db.Delete(tx, key)
+			if errors.Is(err, mvcc.ErrKeyNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			deleted++
+		}
+		return deleted, nil
+	case "scan":
+		return s.scan(tx, args[1:])
+	}
+	return errorReply(fmt.Sprintf("ERR unknown command '%s'", args[0])), nil
+}
+
+// scan 實現 SCAN cursor [MATCH pattern] [COUNT count]。
+// 遊標是按鍵排序後的偏移量；與 Redis 相同，MATCH 在取出 COUNT 個鍵之後才過濾。
+func (s *Server) scan(tx *mvcc.Transaction, args []string) (any, error) {
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		return errorReply("ERR invalid cursor"), nil
+	}
+	pattern, count := "*", 10
+	for i := 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return errorReply("ERR syntax error"), nil
+		}
+		switch strings.ToLower(args[i]) {
+		case "match":
+			pattern = args[i+1]
+		case "count":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return errorReply("ERR value is not an integer or out of range"), nil
+			}
+		default:
+			return errorReply("ERR syntax error"), nil
+		}
+	}
+
+	it, err := s.db.Scan(tx, "", "", cursor+count+1)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]any, 0, count)
+	pos := 0
+	for it.Next() && pos < cursor+count {
+		if pos >= cursor {
+			if globMatch(pattern, it.Key()) {
+				keys = append(keys, it.Key())
+			}
+		}
+		pos++
+	}
+	next := 0
+	if it.Len() > cursor+count {
+		next = cursor + count
+	}
+	return []any{strconv.Itoa(next), keys}, nil
+}
+
+// dbError 將數據庫錯誤轉為錯誤回應
+func dbError(err error) errorReply {
+	return errorReply("ERR " + err.Error())
+}
+
+func writeReply(w writer, reply any) {
+	switch v := reply.(type) {
+	case nil:
+		w.null()
+	case status:
+		w.simple(string(v))
+	case errorReply:
+		w.error(string(v))
+	case int:
+		w.integer(v)
+	case string:
+		w.bulk(v)
+	case []any:
+		if v == nil {
+			w.nullArray()
+			return
+		}
+		w.array(len(v))
+		for _, r := range v {
+			writeReply(w, r)
+		}
+	}
+}
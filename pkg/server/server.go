@@ -0,0 +1,154 @@
+// 以線路協議通過 TCP 提供 mvcc.Database
+package server
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/netserver"
+	"github.com/Mahopanda/golang-mvcc/pkg/utils"
+	"github.com/Mahopanda/golang-mvcc/pkg/wire"
+)
+
+var ErrServerClosed = netserver.ErrServerClosed
+
+// Server 接受連線並在每個連線上依序處理請求。
+// 事務句柄只在建立它的連線內有效，連線斷開時回滾其上所有未結束的事務。
+type Server struct {
+	*netserver.Server // Serve 與 Close
+
+	db *mvcc.Database
+}
+
+func New(db *mvcc.Database) *Server {
+	s := &Server{db: db}
+	s.Server = netserver.New(s.serveConn)
+	return s
+}
+
+// session 一個連線上的事務句柄
+type session struct {
+	txs  map[int]*mvcc.Transaction
+	next int
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	sess := &session{txs: make(map[int]*mvcc.Transaction)}
+	defer func() {
+		for _, tx := range sess.txs {
+			s.db.Rollback(tx)
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		payload, err := wire.ReadFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				wire.WriteFrame(conn, wire.AppendError(nil, err))
+			}
+			return
+		}
+		if err := wire.WriteFrame(conn, s.handle(sess, payload)); err != nil {
+			return
+		}
+	}
+}
+
+// handle 執行一個請求並返回回應的內容
+func (s *Server) handle(sess *session, payload []byte) []byte {
+	d := wire.NewDecoder(payload)
+	op := wire.Op(d.Byte())
+
+	if op == wire.OpBegin {
+		level := mvcc.IsolationLevel(d.Int())
+		readOnly := d.Bool()
+		if d.Err != nil {
+			return wire.AppendError(nil, d.Err)
+		}
+		if level < mvcc.ReadUncommitted || level > mvcc.Serializable {
+			return wire.AppendError(nil, fmt.Errorf("unknown isolation level %d", level))
+		}
+		var tx *mvcc.Transaction
+		if readOnly {
+			tx = s.db.BeginReadOnly()
+		} else {
+			tx = s.db.Begin(level)
+		}
+		sess.next++
+		sess.txs[sess.next] = tx
+		return utils.AppendUvarint([]byte{wire.StatusOK}, sess.next)
+	}
+
+	handle := d.Int()
+	tx, exists := sess.txs[handle]
+	if d.Err == nil && !exists {
+		return wire.AppendError(nil, fmt.Errorf("handle %d: %w", handle, mvcc.ErrInvalidTransaction))
+	}
+
+	resp := []byte{wire.StatusOK}
+	var err error
+	switch op {
+	case wire.OpRead:
+		key := d.String()
+		if d.Err != nil {
+			break
+		}
+		var value string
+		if value, err = s.db.Read(tx, key); err == nil {
+			resp = utils.AppendString(resp, value)
+		}
+	case wire.OpWrite:
+		key, value := d.String(), d.String()
+		if d.Err == nil {
+			err = s.db.Write(tx, key, value)
+		}
+	case wire.OpDelete:
+		key := d.String()
+		if d.Err == nil {
+			err = s.db.Delete(tx, key)
+		}
+	case wire.OpCommit:
+		if d.Err == nil {
+			delete(sess.txs, handle)
+			err = s.db.Commit(tx)
+		}
+	case wire.OpRollback:
+		if d.Err == nil {
+			delete(sess.txs, handle)
+			err = s.db.Rollback(tx)
+		}
+	case wire.OpScan:
+		start, end, limit, reverse := d.String(), d.String(), d.Int(), d.Bool()
+		if d.Err != nil {
+			break
+		}
+		scan := s.db.Scan
+		if reverse {
+			scan = s.db.ReverseScan
+		}
+		var it *mvcc.Iterator
+		if it, err = scan(tx, start, end, limit); err == nil {
+			resp = utils.AppendUvarint(resp, it.Len())
+			for it.Next() {
+				resp = utils.AppendString(resp, it.Key())
+				resp = utils.AppendString(resp, it.Value())
+			}
+		}
+	default:
+		err = fmt.Errorf("unknown op %d", op)
+	}
+
+	if d.Err != nil {
+		return wire.AppendError(nil, d.Err)
+	}
+	if err != nil {
+		// 失敗時事務可能已經被回滾（例如死鎖犧牲者），之後對句柄的操作返回 ErrInvalidTransaction
+		return wire.AppendError(nil, err)
+	}
+	return resp
+}
@@ -0,0 +1,281 @@
+// 場景文件：按順序交錯執行的模擬器命令以及每一步期望的結果
+package simulator
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+)
+
+// Scenario 一個場景。場景文件每行是一個模擬器命令，可以在 => 之後寫出期望的結果：
+//
+//	# 兩個 repeatable_read 事務寫同一個鍵，先提交者勝出
+//	begin t1 rr
+//	begin t2 rr
+//	write t1 k 1
+//	write t2 k 2    => blocked
+//	commit t1       => ok
+//	await t2        => ok                # t1 提交後 t2 得到寫鎖
+//	commit t2       => error write_conflict
+//	get k           => 1
+//
+// 期望可以是：
+//
+//	ok                操作成功
+//	blocked           操作仍在等待鎖
+//	error [code]      操作失敗，code 為 errorCodes 中的錯誤名，省略時接受任何錯誤
+//	<value>           read 或 get 讀到的值
+//	<key=value ...>   scan 的結果，包含空白的鍵值對整個用雙引號括起，沒有結果時寫作 empty
+//
+// 沒有寫出期望的步驟要求命令沒有出錯。
+type Scenario struct {
+	Name  string
+	Steps []Step
+}
+
+// Step 場景中的一步
+type Step struct {
+	Line    int    // 在文件中的行號
+	Command string // 模擬器命令，不包括注釋
+	Expect  string // 期望的結果，空字串表示只要求沒有出錯
+	expect  expectation
+}
+
+// errorCodes 期望中可以使用的錯誤名
+var errorCodes = map[string][]error{
+	"key_not_found":         {mvcc.ErrKeyNotFound, mvcc.ErrVersionNotFound},
+	"invalid_transaction":   {mvcc.ErrInvalidTransaction},
+	"transaction_expired":   {mvcc.ErrTransactionExpired},
+	"read_only_transaction": {mvcc.ErrReadOnlyTransaction},
+	"write_conflict":        {mvcc.ErrWriteConflict},
+	"serialization_failure": {mvcc.ErrSerializationFailure},
+	"deadlock":              {mvcc.ErrDeadlock},
+	"lock_timeout":          {mvcc.ErrLockTimeout},
+	"lock_conflict":         {mvcc.ErrLockConflict},
+	"canceled":              {context.Canceled},
+}
+
+// expectation 解析後的期望
+type expectation struct {
+	kind   string   // ok、blocked、error 或 value，空字串表示只要求沒有出錯
+	code   string   // kind 為 error 時期望的錯誤名
+	values []string // kind 為 value 時期望的值或者鍵值對
+}
+
+func parseExpectation(s string) (expectation, error) {
+	args, err := split(s)
+	if err != nil {
+		return expectation{}, err
+	}
+	if len(args) == 0 {
+		return expectation{}, errors.New("missing expectation after =>")
+	}
+	switch args[0] {
+	case "ok", "blocked":
+		if len(args) > 1 {
+			return expectation{}, fmt.Errorf("unexpected %q after %s", args[1], args[0])
+		}
+		return expectation{kind: args[0]}, nil
+	case "error":
+		if len(args) > 2 {
+			return expectation{}, fmt.Errorf("unexpected %q after error code", args[2])
+		}
+		e := expectation{kind: "error"}
+		if len(args) == 2 {
+			if _, exists := errorCodes[args[1]]; !exists {
+				return expectation{}, fmt.Errorf("unknown error code %q", args[1])
+			}
+			e.code = args[1]
+		}
+		return e, nil
+	}
+	return expectation{kind: "value", values: args}, nil
+}
+
+// match 檢查操作的結果是否符合期望
+func (e expectation) match(o *outcome) bool {
+	switch e.kind {
+	case "":
+		return o.err == nil
+	case "blocked":
+		return o.blocked
+	case "ok":
+		return !o.blocked && o.err == nil
+	case "error":
+		if o.blocked || o.err == nil {
+			return false
+		}
+		if e.code == "" {
+			return true
+		}
+		for _, target := range errorCodes[e.code] {
+			if errors.Is(o.err, target) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if o.blocked || o.err != nil {
+		return false
+	}
+	switch o.kind {
+	case "read":
+		return len(e.values) == 1 && o.value == e.values[0]
+	case "scan":
+		if len(o.pairs) == 0 {
+			return len(e.values) == 1 && e.values[0] == "empty"
+		}
+		if len(o.pairs) != len(e.values) {
+			return false
+		}
+		for i, kv := range o.pairs {
+			if kv.Key+"="+kv.Value != e.values[i] {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// LoadScenario 讀取場景文件，場景以文件路徑命名
+func LoadScenario(path string) (*Scenario, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScenario(path, f)
+}
+
+// ParseScenario 解析場景，命令或期望有誤時返回帶有行號的錯誤
+func ParseScenario(name string, r io.Reader) (*Scenario, error) {
+	sc := &Scenario{Name: name}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		command, expect, hasExpect := cutExpect(scanner.Text())
+		cmd, _, err := parseCommand(command)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		if cmd == "" {
+			if hasExpect {
+				return nil, fmt.Errorf("%s:%d: expectation without a command", name, line)
+			}
+			continue
+		}
+
+		args, _ := split(command)
+		step := Step{Line: line, Command: joinArgs(args)}
+		if hasExpect {
+			if step.expect, err = parseExpectation(expect); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+			}
+			args, _ := split(expect)
+			step.Expect = joinArgs(args)
+		}
+		sc.Steps = append(sc.Steps, step)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// cutExpect 在引號與注釋之外的第一個 => 處將一行分成命令與期望
+func cutExpect(line string) (command, expect string, found bool) {
+	inQuote, tokenStart := false, true
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuote && c == '\\':
+			i++
+		case c == '"':
+			inQuote = !inQuote
+		case !inQuote && c == '#' && tokenStart:
+			return line, "", false
+		case !inQuote && strings.HasPrefix(line[i:], "=>"):
+			return line[:i], line[i+2:], true
+		}
+		tokenStart = !inQuote && (c == ' ' || c == '\t')
+	}
+	return line, "", false
+}
+
+// StepResult 一步的執行結果
+type StepResult struct {
+	Step   Step
+	Got    string // 實際的結果
+	Passed bool
+}
+
+// Report 場景的執行結果
+type Report struct {
+	Scenario string
+	Steps    []StepResult
+}
+
+// Failed 返回失敗的步數
+func (r *Report) Failed() int {
+	failed := 0
+	for _, step := range r.Steps {
+		if !step.Passed {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Write 逐步寫出通過或失敗，失敗的步驟同時寫出實際的結果，最後寫出匯總
+func (r *Report) Write(w io.Writer) {
+	for _, res := range r.Steps {
+		status := "ok  "
+		if !res.Passed {
+			status = "FAIL"
+		}
+		line := fmt.Sprintf("%s %4d: %s", status, res.Step.Line, res.Step.Command)
+		if res.Step.Expect != "" {
+			line += " => " + res.Step.Expect
+		}
+		if !res.Passed {
+			line += " (got " + res.Got + ")"
+		}
+		fmt.Fprintln(w, line)
+	}
+	if failed := r.Failed(); failed > 0 {
+		fmt.Fprintf(w, "FAIL %s: %d of %d steps failed\n", r.Scenario, failed, len(r.Steps))
+	} else {
+		fmt.Fprintf(w, "PASS %s: %d steps\n", r.Scenario, len(r.Steps))
+	}
+}
+
+// Run 在 db 上依次執行場景的每一步並檢查結果。每一步都等到操作完成或開始等待鎖之後才執行下一步，
+// 因此結果不依賴於 goroutine 的調度。trace 接收模擬器的輸出，可以為 io.Discard。
+// 場景結束時回滾仍然活躍的事務。
+func (sc *Scenario) Run(db *mvcc.Database, trace io.Writer) *Report {
+	s := NewSession(db, trace)
+	defer s.Close()
+
+	report := &Report{Scenario: sc.Name}
+	for _, step := range sc.Steps {
+		o := &outcome{}
+		if err := s.Exec(step.Command); err != nil {
+			o.err = err
+		} else if s.last != nil {
+			o = s.last
+		}
+		report.Steps = append(report.Steps, StepResult{
+			Step:   step,
+			Got:    describeOutcome(o),
+			Passed: step.expect.match(o),
+		})
+	}
+	return report
+}
@@ -0,0 +1,667 @@
+// 交互式模擬器：手動交錯執行多個具名事務，觀察版本鏈與鎖表的變化
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+)
+
+// Session 在一個數據庫上執行模擬器命令，結果寫到 out。
+// 每個操作在自己的 goroutine 中執行：操作需要等待鎖時命令立即返回並顯示事務被阻塞，
+// 之後的命令使它得到鎖（或失敗）時再顯示它的結果。Session 不能被多個 goroutine 同時使用。
+type Session struct {
+	db  *mvcc.Database
+	out io.Writer
+
+	// Show 為 true 時每一步之後顯示涉及的鍵的版本鏈以及鎖表
+	Show bool
+
+	txs   map[string]*txn
+	names map[int]string // 事務 ID -> 名字
+	order []string       // 按開始順序排列的事務名字
+	last  *outcome       // 最近一個命令執行的操作的結果，命令沒有執行操作時為 nil
+}
+
+// txn 一個具名事務
+type txn struct {
+	name    string
+	tx      *mvcc.Transaction
+	pending *op      // 還沒有完成的操作，通常在等待鎖
+	done    *outcome // 最近完成的操作的結果
+}
+
+// op 一個在後台執行的操作
+type op struct {
+	kind    string // 命令名
+	desc    string
+	cancel  context.CancelFunc
+	done    chan result
+	blocked bool // 已經顯示過被阻塞
+}
+
+// result 操作的結果
+type result struct {
+	value    string
+	pairs    []mvcc.KeyValue
+	commitTS int
+	err      error
+}
+
+// outcome 一個操作在某一步之後的狀態：仍然被阻塞，或者已經完成並得到 result
+type outcome struct {
+	kind    string
+	desc    string
+	blocked bool
+	result
+}
+
+func NewSession(db *mvcc.Database, out io.Writer) *Session {
+	return &Session{
+		db:    db,
+		out:   out,
+		Show:  true,
+		txs:   make(map[string]*txn),
+		names: make(map[int]string),
+	}
+}
+
+// command 一個模擬器命令，args 不包括命令名
+type command struct {
+	usage string
+	help  string
+	min   int
+	max   int // -1 表示不限制
+	run   func(s *Session, args []string) error
+}
+
+var commands = map[string]command{
+	"begin":    {"begin <tx> [rr|rc|ru|s|ro]", "開始事務，默認為 repeatable_read，ro 為只讀事務", 1, 2, (*Session).begin},
+	"read":     {"read <tx> <key>", "讀取", 2, 2, (*Session).read},
+	"write":    {"write <tx> <key> <value>", "寫入，值可以用雙引號括起", 3, 3, (*Session).write},
+	"delete":   {"delete <tx> <key>", "刪除", 2, 2, (*Session).delete},
+	"scan":     {"scan <tx> [start [end]]", "按鍵的順序掃描 [start, end]", 1, 3, (*Session).scan},
+	"get":      {"get <key>", "在新的只讀事務中讀取最新提交的值", 1, 1, (*Session).get},
+	"await":    {"await <tx>", "顯示事務最近完成的操作的結果，例如之前被阻塞的操作", 1, 1, (*Session).await},
+	"commit":   {"commit <tx>", "提交", 1, 1, (*Session).commit},
+	"rollback": {"rollback <tx>", "回滾，事務被阻塞時放棄等待", 1, 1, (*Session).rollback},
+	"gc":       {"gc", "回收舊版本", 0, 0, (*Session).gc},
+	"dump":     {"dump [key...]", "顯示版本鏈，沒有參數時顯示所有鍵", 0, -1, (*Session).dump},
+	"locks":    {"locks", "顯示鎖表", 0, 0, (*Session).locks},
+	"txs":      {"txs", "列出事務", 0, 0, (*Session).listTxs},
+	"show":     {"show on|off", "每一步之後是否顯示版本鏈與鎖表", 1, 1, (*Session).show},
+}
+
+// Exec 執行一行命令。命令用法錯誤時返回錯誤；操作本身的錯誤（例如寫衝突）作為結果輸出，不返回錯誤。
+func (s *Session) Exec(line string) error {
+	s.last = nil
+	name, args, err := parseCommand(line)
+	if err != nil || name == "" {
+		return err
+	}
+	if name == "help" {
+		s.help()
+		return nil
+	}
+	return commands[name].run(s, args)
+}
+
+// parseCommand 解析並驗證一行命令，返回小寫的命令名與參數；空行與注釋返回空的命令名
+func parseCommand(line string) (string, []string, error) {
+	args, err := split(line)
+	if err != nil || len(args) == 0 {
+		return "", nil, err
+	}
+	name := strings.ToLower(args[0])
+	if name == "help" {
+		return name, nil, nil
+	}
+	cmd, exists := commands[name]
+	if !exists {
+		return "", nil, fmt.Errorf("unknown command %q, type help for a list of commands", args[0])
+	}
+	if n := len(args) - 1; n < cmd.min || (cmd.max >= 0 && n > cmd.max) {
+		return "", nil, fmt.Errorf("usage: %s", cmd.usage)
+	}
+	return name, args[1:], nil
+}
+
+// Close 放棄所有被阻塞的操作並回滾仍然活躍的事務
+func (s *Session) Close() {
+	// 先回滾沒有被阻塞的事務釋放它們的鎖，不響應取消的等待（例如掃描）因此也能結束
+	for _, name := range s.order {
+		if t := s.txs[name]; t.pending != nil {
+			t.pending.cancel()
+		} else {
+			s.db.Rollback(t.tx)
+		}
+	}
+	for _, name := range s.order {
+		if t := s.txs[name]; t.pending != nil {
+			<-t.pending.done
+			t.pending = nil
+			s.db.Rollback(t.tx)
+		}
+	}
+}
+
+func (s *Session) help() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(s.out, "  %-28s %s\n", commands[name].usage, commands[name].help)
+	}
+	fmt.Fprintf(s.out, "  %-28s %s\n", "help", "顯示本說明")
+}
+
+func (s *Session) begin(args []string) error {
+	name := args[0]
+	if t, exists := s.txs[name]; exists && (t.pending != nil || t.tx.Status == mvcc.Active) {
+		return fmt.Errorf("%s is still active", name)
+	}
+
+	var tx *mvcc.Transaction
+	if len(args) > 1 && (args[1] == "ro" || args[1] == "read_only") {
+		tx = s.db.BeginReadOnly()
+	} else {
+		level := mvcc.RepeatableRead
+		if len(args) > 1 {
+			var err error
+			if level, err = mvcc.ParseIsolationLevel(args[1]); err != nil {
+				return err
+			}
+		}
+		tx = s.db.Begin(level)
+	}
+
+	if _, exists := s.txs[name]; !exists {
+		s.order = append(s.order, name)
+	}
+	s.txs[name] = &txn{name: name, tx: tx}
+	s.names[tx.ID] = name
+	fmt.Fprintf(s.out, "%s: began tx %d (%s, read_ts %d)\n", name, tx.ID, describeTx(tx), tx.ReadTS)
+	return nil
+}
+
+func (s *Session) read(args []string) error {
+	key := args[1]
+	return s.run(args[0], "read", "read "+quoteArg(key), []string{key}, func(ctx context.Context, tx *mvcc.Transaction) result {
+		value, err := s.db.ReadCtx(ctx, tx, key)
+		return result{value: value, err: err}
+	})
+}
+
+func (s *Session) write(args []string) error {
+	key, value := args[1], args[2]
+	return s.run(args[0], "write", "write "+quoteArg(key)+" "+strconv.Quote(value), []string{key}, func(ctx context.Context, tx *mvcc.Transaction) result {
+		return result{err: s.db.WriteCtx(ctx, tx, key, value)}
+	})
+}
+
+func (s *Session) delete(args []string) error {
+	key := args[1]
+	return s.run(args[0], "delete", "delete "+quoteArg(key), []string{key}, func(ctx context.Context, tx *mvcc.Transaction) result {
+		return result{err: s.db.DeleteCtx(ctx, tx, key)}
+	})
+}
+
+func (s *Session) scan(args []string) error {
+	var start, end string
+	if len(args) > 1 {
+		start = args[1]
+	}
+	if len(args) > 2 {
+		end = args[2]
+	}
+	return s.run(args[0], "scan", "scan", nil, func(ctx context.Context, tx *mvcc.Transaction) result {
+		it, err := s.db.ScanCtx(ctx, tx, start, end, 0)
+		if err != nil {
+			return result{err: err}
+		}
+		pairs := make([]mvcc.KeyValue, 0, it.Len())
+		for it.Next() {
+			pairs = append(pairs, mvcc.KeyValue{Key: it.Key(), Value: it.Value()})
+		}
+		return result{pairs: pairs}
+	})
+}
+
+func (s *Session) commit(args []string) error {
+	t, err := s.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	if t.pending != nil {
+		return blockedError(t)
+	}
+	return s.run(args[0], "commit", "commit", writtenKeys(t.tx), func(ctx context.Context, tx *mvcc.Transaction) result {
+		err := s.db.CommitCtx(ctx, tx)
+		return result{err: err, commitTS: tx.WriteTS}
+	})
+}
+
+func (s *Session) rollback(args []string) error {
+	t, err := s.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	if t.pending != nil {
+		// 取消等待中的操作，事務因此被回滾
+		t.pending.cancel()
+		s.finish(t, <-t.pending.done)
+	}
+	return s.run(args[0], "rollback", "rollback", writtenKeys(t.tx), func(ctx context.Context, tx *mvcc.Transaction) result {
+		return result{err: s.db.Rollback(tx)}
+	})
+}
+
+func (s *Session) get(args []string) error {
+	key := args[0]
+	o := &outcome{kind: "read", desc: "get " + quoteArg(key)}
+	o.err = s.db.View(func(tx *mvcc.Transaction) error {
+		var err error
+		o.value, err = s.db.Read(tx, key)
+		return err
+	})
+	s.last = o
+	fmt.Fprintf(s.out, "%s: %s\n", o.desc, describeOutcome(o))
+	return nil
+}
+
+func (s *Session) await(args []string) error {
+	t, err := s.lookup(args[0])
+	if err != nil {
+		return err
+	}
+	if t.pending != nil {
+		s.last = &outcome{kind: t.pending.kind, desc: t.pending.desc, blocked: true}
+	} else if t.done != nil {
+		s.last = t.done
+	} else {
+		return fmt.Errorf("%s has not completed any operation", t.name)
+	}
+	s.printOutcome(t, s.last)
+	return nil
+}
+
+func (s *Session) gc(args []string) error {
+	fmt.Fprintf(s.out, "gc: removed %d versions\n", s.db.CleanupOldVersions())
+	if s.Show {
+		s.printChains(s.keys())
+		s.printLocks()
+	}
+	return nil
+}
+
+func (s *Session) dump(args []string) error {
+	keys := args
+	if len(keys) == 0 {
+		keys = s.keys()
+	}
+	s.printChains(keys)
+	return nil
+}
+
+func (s *Session) locks(args []string) error {
+	s.printLocks()
+	return nil
+}
+
+func (s *Session) listTxs(args []string) error {
+	for _, name := range s.order {
+		t := s.txs[name]
+		var status string
+		if t.pending != nil {
+			status = "blocked on " + t.pending.desc
+		} else {
+			status = statusNames[t.tx.Status]
+		}
+		fmt.Fprintf(s.out, "  %s: tx %d (%s, read_ts %d) %s\n", name, t.tx.ID, describeTx(t.tx), t.tx.ReadTS, status)
+	}
+	return nil
+}
+
+func (s *Session) show(args []string) error {
+	switch strings.ToLower(args[0]) {
+	case "on":
+		s.Show = true
+	case "off":
+		s.Show = false
+	default:
+		return errors.New("usage: show on|off")
+	}
+	return nil
+}
+
+func (s *Session) lookup(name string) (*txn, error) {
+	t, exists := s.txs[name]
+	if !exists {
+		return nil, fmt.Errorf("no transaction named %s, start one with begin %s", name, name)
+	}
+	return t, nil
+}
+
+// run 在後台執行事務 name 上的操作，等到它完成或開始等待鎖，然後顯示結果與 keys 的版本鏈
+func (s *Session) run(name, kind, desc string, keys []string, fn func(ctx context.Context, tx *mvcc.Transaction) result) error {
+	t, err := s.lookup(name)
+	if err != nil {
+		return err
+	}
+	if t.pending != nil {
+		return blockedError(t)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	o := &op{kind: kind, desc: desc, cancel: cancel, done: make(chan result, 1)}
+	go func() { o.done <- fn(ctx, t.tx) }()
+	t.pending = o
+
+	s.settle(t)
+	if t.pending != nil {
+		s.last = &outcome{kind: kind, desc: desc, blocked: true}
+	} else {
+		s.last = t.done
+	}
+
+	if s.Show {
+		s.printChains(keys)
+		s.printLocks()
+	}
+	return nil
+}
+
+// settle 等到所有事務的操作都已經完成或者確認在鎖上等待，然後先顯示 t 的結果，再按事務開始的順序顯示其他事務的結果。
+// t 的操作可能使其他被阻塞的操作得到鎖，或者使另一個事務被選為死鎖犧牲者、回滾後釋放 t 在等的鎖，
+// 因此只有沒有任何操作仍在執行時才能確定誰被阻塞，結果才不依賴於 goroutine 的調度。
+// 操作第一次被確認等待時顯示它在等誰。
+func (s *Session) settle(t *txn) {
+	results := make(map[*txn]result)
+	waits := make(map[*txn]mvcc.LockInfo)
+	for {
+		for _, name := range s.order {
+			u := s.txs[name]
+			if _, done := results[u]; u.pending == nil || done {
+				continue
+			}
+			select {
+			case r := <-u.pending.done:
+				results[u] = r
+			default:
+			}
+		}
+
+		// 在同一份鎖表快照中確認其餘的操作都在等待，否則仍有操作在執行
+		clear(waits)
+		locks := s.db.Locks()
+		stable := true
+		for _, name := range s.order {
+			u := s.txs[name]
+			if _, done := results[u]; u.pending == nil || done {
+				continue
+			}
+			lock, waiting := waitingIn(locks, u.tx.ID)
+			if !waiting {
+				stable = false
+				break
+			}
+			waits[u] = lock
+		}
+		if stable {
+			break
+		}
+		time.Sleep(100 * time.Microsecond)
+	}
+
+	report := func(u *txn) {
+		if r, done := results[u]; done {
+			s.finish(u, r)
+			return
+		}
+		if o := u.pending; !o.blocked {
+			o.blocked = true
+			lock := waits[u]
+			fmt.Fprintf(s.out, "%s: %s: blocked waiting for %s lock on %s held by %s\n",
+				u.name, o.desc, lock.Type, quoteArg(lock.Key), s.holders(lock.Key))
+		}
+	}
+	report(t)
+	for _, name := range s.order {
+		if u := s.txs[name]; u != t && u.pending != nil {
+			report(u)
+		}
+	}
+}
+
+func blockedError(t *txn) error {
+	return fmt.Errorf("%s is blocked on %s; release the lock it is waiting for or roll it back", t.name, t.pending.desc)
+}
+
+// finish 顯示已經完成的操作的結果
+func (s *Session) finish(t *txn, r result) {
+	o := t.pending
+	t.pending = nil
+	o.cancel()
+	t.done = &outcome{kind: o.kind, desc: o.desc, result: r}
+	s.printOutcome(t, t.done)
+}
+
+// waitingIn 返回事務在鎖表快照 locks 中正在等待的鎖
+func waitingIn(locks []mvcc.LockInfo, txID int) (mvcc.LockInfo, bool) {
+	for _, lock := range locks {
+		if lock.Waiting && lock.TxID == txID {
+			return lock, true
+		}
+	}
+	return mvcc.LockInfo{}, false
+}
+
+// holders 列出 key 上的鎖的持有者
+func (s *Session) holders(key string) string {
+	var names []string
+	for _, lock := range s.db.Locks() {
+		if lock.Key == key && !lock.Waiting {
+			names = append(names, s.name(lock.TxID))
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// printOutcome 顯示事務 t 上操作的結果
+func (s *Session) printOutcome(t *txn, o *outcome) {
+	msg := describeOutcome(o)
+	if o.err != nil && o.kind != "rollback" && t.tx.Status == mvcc.Aborted {
+		msg += " (" + t.name + " aborted)"
+	}
+	fmt.Fprintf(s.out, "%s: %s: %s\n", t.name, o.desc, msg)
+}
+
+// describeOutcome 描述操作的結果：讀到的值、掃描結果、錯誤或者仍然被阻塞
+func describeOutcome(o *outcome) string {
+	switch {
+	case o.blocked:
+		return "still blocked"
+	case o.err != nil:
+		return "error: " + o.err.Error()
+	case o.kind == "commit":
+		return fmt.Sprintf("committed at ts %d", o.commitTS)
+	case o.kind == "rollback":
+		return "rolled back"
+	case o.kind == "read":
+		return strconv.Quote(o.value)
+	case o.kind == "scan":
+		items := make([]string, len(o.pairs))
+		for i, kv := range o.pairs {
+			items[i] = kv.Key + "=" + kv.Value
+		}
+		return "[" + joinArgs(items) + "]"
+	}
+	return "ok"
+}
+
+// printChains 顯示各個鍵的版本鏈，從舊到新排列
+func (s *Session) printChains(keys []string) {
+	data := s.db.GetData()
+	for _, key := range keys {
+		var versions []*mvcc.Version
+		if record, exists := data[key]; exists {
+			versions = record.GetVersions()
+		}
+		if len(versions) == 0 {
+			fmt.Fprintf(s.out, "  %s: (no versions)\n", quoteArg(key))
+			continue
+		}
+		items := make([]string, len(versions))
+		for i, v := range versions {
+			items[i] = s.describeVersion(v)
+		}
+		fmt.Fprintf(s.out, "  %s: %s\n", quoteArg(key), strings.Join(items, " -> "))
+	}
+}
+
+// describeVersion 以 [創建者 提交時間戳範圍 值] 的形式描述一個版本
+func (s *Session) describeVersion(v *mvcc.Version) string {
+	value := strconv.Quote(v.Value)
+	if v.Deleted {
+		value = "<deleted>"
+	}
+	if !v.Committed {
+		return fmt.Sprintf("[%s uncommitted %s]", s.name(v.TxID), value)
+	}
+	end := ""
+	if v.EndTS > 0 {
+		end = strconv.Itoa(v.EndTS)
+	}
+	return fmt.Sprintf("[%s @%d-%s %s]", s.name(v.TxID), v.Timestamp, end, value)
+}
+
+// printLocks 顯示鎖表，每個鍵一行
+func (s *Session) printLocks() {
+	locks := s.db.Locks()
+	if len(locks) == 0 {
+		fmt.Fprintln(s.out, "  locks: (none)")
+		return
+	}
+	for i := 0; i < len(locks); {
+		key := locks[i].Key
+		var held, waiting []string
+		for ; i < len(locks) && locks[i].Key == key; i++ {
+			item := s.name(locks[i].TxID) + " " + locks[i].Type.String()
+			if locks[i].Waiting {
+				waiting = append(waiting, item)
+			} else {
+				held = append(held, item)
+			}
+		}
+		line := "  lock " + quoteArg(key) + ": held by " + strings.Join(held, ", ")
+		if len(waiting) > 0 {
+			line += "; waiting " + strings.Join(waiting, ", ")
+		}
+		fmt.Fprintln(s.out, line)
+	}
+}
+
+// name 返回事務的名字，不是在本會話中開始的事務顯示為 tx<ID>
+func (s *Session) name(txID int) string {
+	if name, exists := s.names[txID]; exists {
+		return name
+	}
+	return "tx" + strconv.Itoa(txID)
+}
+
+// keys 返回數據庫中所有的鍵
+func (s *Session) keys() []string {
+	data := s.db.GetData()
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+var statusNames = map[mvcc.TransactionStatus]string{
+	mvcc.Active:    "active",
+	mvcc.Committed: "committed",
+	mvcc.Aborted:   "aborted",
+}
+
+func describeTx(tx *mvcc.Transaction) string {
+	if tx.ReadOnly {
+		return "read_only"
+	}
+	return tx.IsolationLevel.String()
+}
+
+// writtenKeys 返回事務寫過的鍵
+func writtenKeys(tx *mvcc.Transaction) []string {
+	keys := make([]string, 0, len(tx.WriteSet))
+	for key := range tx.WriteSet {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// quoteArg 參數為空或包含空白、引號時加上雙引號，使輸出可以作為命令再次輸入
+func quoteArg(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t\r\n\"#") {
+		return strconv.Quote(arg)
+	}
+	return arg
+}
+
+// joinArgs 將參數以空白連接，是 split 的逆操作
+func joinArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = quoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// split 以空白分隔參數；以雙引號開始的參數按 Go 字串字面量解析，可以包含空白；
+// 以 # 開始的參數及其後的內容是注釋
+func split(line string) ([]string, error) {
+	var args []string
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+		if line == "" || line[0] == '#' {
+			return args, nil
+		}
+		if line[0] == '"' {
+			end := 1
+			for end < len(line) && line[end] != '"' {
+				if line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, errors.New("unterminated quoted string")
+			}
+			arg, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted string %s", line[:end+1])
+			}
+			args = append(args, arg)
+			line = line[end+1:]
+			continue
+		}
+		end := strings.IndexAny(line, " \t\r")
+		if end < 0 {
+			end = len(line)
+		}
+		args = append(args, line[:end])
+		line = line[end:]
+	}
+}
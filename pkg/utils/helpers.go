@@ -0,0 +1,43 @@
+// 二進位編碼輔助函數
+package utils
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// ErrShortBuffer 表示緩衝區內容不足以解碼
+var ErrShortBuffer = errors.New("short buffer")
+
+// AppendUvarint 以 uvarint 編碼附加一個非負整數
+func AppendUvarint(buf []byte, v int) []byte {
+	return binary.AppendUvarint(buf, uint64(v))
+}
+
+// AppendString 附加長度前綴的字串
+func AppendString(buf []byte, s string) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+// ReadUvarint 從緩衝區讀取 uvarint，返回值與剩餘內容；超出 int 範圍的值視為損壞
+func ReadUvarint(buf []byte) (int, []byte, error) {
+	v, n := binary.Uvarint(buf)
+	if n <= 0 || v > math.MaxInt {
+		return 0, buf, ErrShortBuffer
+	}
+	return int(v), buf[n:], nil
+}
+
+// ReadString 從緩衝區讀取長度前綴的字串，返回字串與剩餘內容
+func ReadString(buf []byte) (string, []byte, error) {
+	n, rest, err := ReadUvarint(buf)
+	if err != nil {
+		return "", buf, err
+	}
+	if n < 0 || n > len(rest) {
+		return "", buf, ErrShortBuffer
+	}
+	return string(rest[:n]), rest[n:], nil
+}
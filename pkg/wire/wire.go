@@ -0,0 +1,188 @@
+// mvcc-server 的線路協議
+//
+// 每個請求與回應都是一個幀：4 bytes 大端序長度 + 內容。
+// 請求內容為 1 byte 操作碼加上各操作的參數；回應內容為 1 byte 狀態，
+// 成功時接著返回值，失敗時接著錯誤碼與錯誤訊息。整數以 uvarint、字串以長度前綴編碼。
+package wire
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/utils"
+)
+
+// MaxFrameSize 單個幀內容的上限，超過時視為協議錯誤
+const MaxFrameSize = 64 << 20
+
+// Op 請求的操作碼
+type Op byte
+
+const (
+	OpBegin    Op = iota + 1 // level, readOnly -> handle
+	OpRead                   // handle, key -> value
+	OpWrite                  // handle, key, value
+	OpDelete                 // handle, key
+	OpCommit                 // handle
+	OpRollback               // handle
+	OpScan                   // handle, start, end, limit, reverse -> count, (key, value)...
+)
+
+// 回應的狀態
+const (
+	StatusOK byte = iota
+	StatusError
+)
+
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// WriteFrame 寫出一個幀
+func WriteFrame(w io.Writer, payload []byte) error {
+	if len(payload) > MaxFrameSize {
+		return ErrFrameTooLarge
+	}
+	buf := make([]byte, 4, 4+len(payload))
+	binary.BigEndian.PutUint32(buf, uint32(len(payload)))
+	_, err := w.Write(append(buf, payload...))
+	return err
+}
+
+// ReadFrame 讀取一個幀的內容
+func ReadFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(header[:])
+	if n > MaxFrameSize {
+		return nil, ErrFrameTooLarge
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// Decoder 依序讀取幀內容中的欄位，遇到的第一個錯誤保存在 Err 中
+type Decoder struct {
+	buf []byte
+	Err error
+}
+
+func NewDecoder(payload []byte) *Decoder {
+	return &Decoder{buf: payload}
+}
+
+func (d *Decoder) Byte() byte {
+	if d.Err != nil {
+		return 0
+	}
+	if len(d.buf) == 0 {
+		d.Err = utils.ErrShortBuffer
+		return 0
+	}
+	b := d.buf[0]
+	d.buf = d.buf[1:]
+	return b
+}
+
+func (d *Decoder) Bool() bool {
+	return d.Byte() != 0
+}
+
+func (d *Decoder) Int() int {
+	if d.Err != nil {
+		return 0
+	}
+	var v int
+	v, d.buf, d.Err = utils.ReadUvarint(d.buf)
+	return v
+}
+
+func (d *Decoder) String() string {
+	if d.Err != nil {
+		return ""
+	}
+	var s string
+	s, d.buf, d.Err = utils.ReadString(d.buf)
+	return s
+}
+
+// AppendBool 以 1 byte 附加布爾值
+func AppendBool(buf []byte, v bool) []byte {
+	if v {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+// errorCodes 錯誤碼對應的哨兵錯誤，碼為索引；同時滿足多個錯誤時取排在前面的
+var errorCodes = []error{
+	nil,
+	mvcc.ErrKeyNotFound,
+	mvcc.ErrVersionNotFound,
+	mvcc.ErrInvalidTransaction,
+	mvcc.ErrTransactionExpired,
+	mvcc.ErrReadOnlyTransaction,
+	mvcc.ErrWriteConflict,
+	mvcc.ErrSerializationFailure,
+	mvcc.ErrDeadlock,
+	mvcc.ErrLockTimeout,
+	mvcc.ErrLockConflict,
+	mvcc.ErrSavepointNotFound,
+	mvcc.ErrInvalidEncoding,
+}
+
+// AppendError 附加錯誤回應：狀態、錯誤碼與錯誤訊息
+func AppendError(buf []byte, err error) []byte {
+	code := 0
+	for i, sentinel := range errorCodes[1:] {
+		if errors.Is(err, sentinel) {
+			code = i + 1
+			break
+		}
+	}
+	buf = append(buf, StatusError)
+	buf = utils.AppendUvarint(buf, code)
+	return utils.AppendString(buf, err.Error())
+}
+
+// Error 服務端返回的錯誤，errors.Is 對服務端錯誤所屬的哨兵錯誤成立
+type Error struct {
+	Code    int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	if e.Code <= 0 || e.Code >= len(errorCodes) {
+		return nil
+	}
+	return errorCodes[e.Code]
+}
+
+// DecodeResponse 讀取回應的狀態，服務端返回錯誤時解碼為 *Error
+func DecodeResponse(payload []byte) (*Decoder, error) {
+	d := NewDecoder(payload)
+	switch d.Byte() {
+	case StatusOK:
+		return d, d.Err
+	case StatusError:
+		e := &Error{Code: d.Int(), Message: d.String()}
+		if d.Err != nil {
+			return nil, d.Err
+		}
+		return nil, e
+	}
+	if d.Err != nil {
+		return nil, d.Err
+	}
+	return nil, fmt.Errorf("unknown response status %d", payload[0])
+}
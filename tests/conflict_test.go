@@ -0,0 +1,106 @@
+package mvcc_test
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試丟失更新：tx1 基於舊快照寫入，tx2 已經先提交了同一個 key
+func TestLostUpdateFirstCommitterWins(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	init := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(init, "counter", "0"))
+	require.NoError(t, db.Commit(init))
+
+	tx1 := db.Begin(mvcc.RepeatableRead)
+	tx2 := db.Begin(mvcc.RepeatableRead)
+
+	require.NoError(t, db.Write(tx2, "counter", "1"))
+	require.NoError(t, db.Commit(tx2))
+
+	val, err := db.Read(tx1, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, "0", val, "tx1 應該讀到自己快照中的值")
+	require.NoError(t, db.Write(tx1, "counter", "1"))
+	assert.ErrorIs(t, db.Commit(tx1), mvcc.ErrWriteConflict)
+	assert.Equal(t, mvcc.Aborted, tx1.Status)
+}
+
+// 可串行化事務不加鎖，兩個事務的未提交版本同時存在，後提交的一方失敗
+func TestSerializableWriteConflict(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.Serializable)
+	tx2 := db.Begin(mvcc.Serializable)
+	require.NoError(t, db.Write(tx1, "key1", "tx1"))
+	require.NoError(t, db.Write(tx2, "key1", "tx2"))
+
+	assert.NoError(t, db.Commit(tx2))
+	assert.ErrorIs(t, db.Commit(tx1), mvcc.ErrWriteConflict)
+
+	// ReadCommitted 允許覆蓋之後提交的版本
+	tx3 := db.Begin(mvcc.ReadCommitted)
+	tx4 := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(tx4, "key1", "tx4"))
+	require.NoError(t, db.Commit(tx4))
+	require.NoError(t, db.Write(tx3, "key1", "tx3"))
+	assert.NoError(t, db.Commit(tx3))
+}
+
+// 並發遞增計數器，失敗的事務重試，最終結果不能丟失任何一次更新
+func TestConcurrentIncrementNoLostUpdate(t *testing.T) {
+	for _, level := range []mvcc.IsolationLevel{mvcc.RepeatableRead, mvcc.Serializable} {
+		db := mvcc.NewDatabase()
+		init := db.Begin(mvcc.ReadCommitted)
+		require.NoError(t, db.Write(init, "counter", "0"))
+		require.NoError(t, db.Commit(init))
+
+		const workers = 10
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for i := 0; i < workers; i++ {
+			go func() {
+				defer wg.Done()
+				for {
+					err := increment(db, level)
+					if err == nil {
+						return
+					}
+					if !errors.Is(err, mvcc.ErrWriteConflict) && !errors.Is(err, mvcc.ErrDeadlock) &&
+						!errors.Is(err, mvcc.ErrSerializationFailure) {
+						t.Errorf("非預期的錯誤: %v", err)
+						return
+					}
+				}
+			}()
+		}
+		wg.Wait()
+
+		tx := db.Begin(mvcc.ReadCommitted)
+		val, err := db.Read(tx, "counter")
+		assert.NoError(t, err)
+		assert.Equal(t, strconv.Itoa(workers), val, "level %d", level)
+	}
+}
+
+func increment(db *mvcc.Database, level mvcc.IsolationLevel) error {
+	tx := db.Begin(level)
+	val, err := db.Read(tx, "counter")
+	if err != nil {
+		db.Rollback(tx)
+		return err
+	}
+	n, _ := strconv.Atoi(val)
+	if err := db.Write(tx, "counter", strconv.Itoa(n+1)); err != nil {
+		db.Rollback(tx)
+		return err
+	}
+	return db.Commit(tx)
+}
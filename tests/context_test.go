@@ -0,0 +1,68 @@
+package mvcc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試取消 ctx 會中止鎖等待並回滾事務，釋放它持有的鎖
+func TestContextCancelLockWait(t *testing.T) {
+	db := mvcc.NewDatabase()
+	holder := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(holder, "key1", "holder"))
+
+	tx, err := db.BeginTx(context.Background(), mvcc.TxOptions{Isolation: mvcc.RepeatableRead})
+	require.NoError(t, err)
+	require.NoError(t, db.Write(tx, "key2", "tx"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	err = db.WriteCtx(ctx, tx, "key1", "tx")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "transaction")
+	assert.Equal(t, mvcc.Aborted, tx.Status)
+
+	// tx 回滾後釋放了 key2 的寫鎖
+	other := db.Begin(mvcc.RepeatableRead)
+	assert.NoError(t, db.Write(other, "key2", "other"))
+	assert.NoError(t, db.Commit(other))
+	assert.NoError(t, db.Commit(holder))
+}
+
+// 測試 BeginTx 的上下文到期後，事務的下一次操作會回滾事務
+func TestContextTransactionDeadline(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	tx, err := db.BeginTx(ctx, mvcc.TxOptions{Isolation: mvcc.ReadCommitted})
+	require.NoError(t, err)
+	require.NoError(t, db.Write(tx, "key1", "v1"))
+
+	<-ctx.Done()
+	_, err = db.ReadCtx(context.Background(), tx, "key1")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, mvcc.Aborted, tx.Status)
+	assert.ErrorIs(t, db.Commit(tx), mvcc.ErrInvalidTransaction)
+
+	_, err = db.BeginTx(ctx, mvcc.TxOptions{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+// 測試提交前 ctx 已經取消時不會提交
+func TestCommitCtxCancelled(t *testing.T) {
+	db := mvcc.NewDatabase()
+	tx := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(tx, "key1", "v1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, db.CommitCtx(ctx, tx), context.Canceled)
+	assert.Equal(t, mvcc.Aborted, tx.Status)
+	assert.Empty(t, db.GetData()["key1"].GetVersions())
+}
@@ -0,0 +1,65 @@
+package mvcc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試鎖等待超時返回帶有鍵與持有者的 LockConflictError
+func TestLockConflictError(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithLockTimeout(20 * time.Millisecond))
+	holder := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(holder, "key1", "holder"))
+
+	tx := db.Begin(mvcc.RepeatableRead)
+	err := db.Write(tx, "key1", "tx")
+
+	var conflict *mvcc.LockConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, tx.ID, conflict.TxID)
+	assert.Equal(t, "key1", conflict.Key)
+	assert.Equal(t, holder.ID, conflict.HolderTxID)
+	assert.Equal(t, mvcc.WriteLock, conflict.Requested)
+	assert.ErrorIs(t, err, mvcc.ErrLockTimeout)
+	assert.ErrorIs(t, err, mvcc.ErrLockConflict)
+	assert.True(t, mvcc.IsRetryable(err))
+
+	// 範圍鎖衝突同樣是 LockConflictError
+	lock := mvcc.NewSerializableLock()
+	require.NoError(t, lock.LockRange("a"))
+	err = lock.LockRange("a")
+	assert.ErrorIs(t, err, mvcc.ErrRangeLocked)
+	assert.ErrorAs(t, err, &conflict)
+}
+
+// 測試提交驗證失敗返回帶有鍵與時間戳的 SerializationError
+func TestSerializationError(t *testing.T) {
+	db := mvcc.NewDatabase()
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "v1")
+	}))
+
+	tx1 := db.Begin(mvcc.Serializable)
+	tx2 := db.Begin(mvcc.Serializable)
+	require.NoError(t, db.Write(tx1, "key1", "tx1"))
+	require.NoError(t, db.Write(tx2, "key1", "tx2"))
+	require.NoError(t, db.Commit(tx2))
+
+	err := db.Commit(tx1)
+	var serr *mvcc.SerializationError
+	require.ErrorAs(t, err, &serr)
+	assert.Equal(t, tx1.ID, serr.TxID)
+	assert.Equal(t, "key1", serr.Key)
+	assert.Equal(t, tx1.ReadTS, serr.ReadTS)
+	assert.Equal(t, tx2.WriteTS, serr.ConflictingTS)
+	assert.ErrorIs(t, err, mvcc.ErrWriteConflict)
+	assert.True(t, mvcc.IsRetryable(err))
+
+	assert.False(t, mvcc.IsRetryable(mvcc.ErrKeyNotFound))
+	assert.False(t, mvcc.IsRetryable(errors.New("other")))
+}
@@ -0,0 +1,87 @@
+package mvcc_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeVersions(t *testing.T, db *mvcc.Database, key string, n int) {
+	for i := 0; i < n; i++ {
+		require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+			return db.Write(tx, key, fmt.Sprintf("v%d", i))
+		}))
+	}
+}
+
+// 測試版本鏈超過長度閾值時觸發回收
+func TestGCChainLengthTrigger(t *testing.T) {
+	cycles := make(chan mvcc.GCStats, 16)
+	db := mvcc.NewDatabase(mvcc.WithGC(mvcc.GCPolicy{
+		MaxChainLength: 4,
+		OnCycle:        func(s mvcc.GCStats) { cycles <- s },
+	}))
+	defer db.Close()
+
+	writeVersions(t, db, "key1", 4)
+	select {
+	case s := <-cycles:
+		t.Fatalf("版本鏈未超過閾值時不應該回收: %+v", s)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	writeVersions(t, db, "key1", 1)
+	select {
+	case s := <-cycles:
+		assert.Equal(t, 4, s.Versions)
+	case <-time.After(time.Second):
+		t.Fatal("版本鏈超過閾值後應該觸發回收")
+	}
+	assert.Len(t, db.GetData()["key1"].GetVersions(), 1)
+}
+
+// 測試定期回收分批處理所有記錄，並移除已刪除的記錄
+func TestGCIntervalBatches(t *testing.T) {
+	cycles := make(chan mvcc.GCStats, 16)
+	db := mvcc.NewDatabase(mvcc.WithGC(mvcc.GCPolicy{
+		Interval:  10 * time.Millisecond,
+		BatchSize: 3,
+		OnCycle:   func(s mvcc.GCStats) { cycles <- s },
+	}))
+
+	for i := 0; i < 10; i++ {
+		writeVersions(t, db, fmt.Sprintf("key%02d", i), 2)
+	}
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Delete(tx, "key00")
+	}))
+
+	// 等待一輪在所有寫入之後開始的完整回收
+	reclaimed := 0
+	deadline := time.After(time.Second)
+	for reclaimed < 12 {
+		select {
+		case s := <-cycles:
+			reclaimed += s.Versions
+		case <-deadline:
+			t.Fatalf("回收的版本數不足: %d", reclaimed)
+		}
+	}
+	assert.Equal(t, 12, reclaimed)
+	assert.NotContains(t, db.GetData(), "key00")
+	for key, record := range db.GetData() {
+		assert.Len(t, record.GetVersions(), 1, key)
+	}
+
+	// Close 之後不再運行
+	require.NoError(t, db.Close())
+	for len(cycles) > 0 {
+		<-cycles
+	}
+	time.Sleep(30 * time.Millisecond)
+	assert.Empty(t, cycles)
+}
@@ -0,0 +1,206 @@
+package mvcc_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/httpapi"
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// httpDo 發送請求，返回狀態碼並把 JSON 回應解碼到 out（out 為 nil 時忽略回應）
+func httpDo(t *testing.T, srv *httptest.Server, method, path, body string, out any) int {
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	require.NoError(t, err)
+	resp, err := srv.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+type httpTx struct {
+	ID        int    `json:"id"`
+	Isolation string `json:"isolation"`
+	Status    string `json:"status"`
+	CommitTS  int    `json:"commit_ts"`
+}
+
+type httpError struct {
+	Error string `json:"error"`
+	Code  string `json:"code"`
+}
+
+func beginHTTP(t *testing.T, srv *httptest.Server, isolation string) string {
+	var tx httpTx
+	status := httpDo(t, srv, "POST", "/tx", `{"isolation": "`+isolation+`"}`, &tx)
+	require.Equal(t, http.StatusCreated, status)
+	return fmt.Sprintf("/tx/%d", tx.ID)
+}
+
+// 測試事務資源的生命週期與錯誤映射
+func TestHTTPTransactionLifecycle(t *testing.T) {
+	srv := httptest.NewServer(httpapi.New(mvcc.NewDatabase()))
+	defer srv.Close()
+
+	tx := beginHTTP(t, srv, "repeatable_read")
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", tx+"/keys/key1", `{"value": "v1"}`, nil))
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", tx+"/keys/key2", `{"value": "v2"}`, nil))
+	assert.Equal(t, http.StatusNoContent, httpDo(t, srv, "DELETE", tx+"/keys/key2", "", nil))
+
+	var kv map[string]string
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "GET", tx+"/keys/key1", "", &kv))
+	assert.Equal(t, map[string]string{"key": "key1", "value": "v1"}, kv)
+
+	var herr httpError
+	assert.Equal(t, http.StatusNotFound, httpDo(t, srv, "GET", tx+"/keys/key2", "", &herr))
+	assert.Equal(t, "key_not_found", herr.Code)
+	assert.Equal(t, http.StatusBadRequest, httpDo(t, srv, "PUT", tx+"/keys/key3", `"v3"`, nil))
+
+	var committed httpTx
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "POST", tx+"/commit", "", &committed))
+	assert.Equal(t, "committed", committed.Status)
+	assert.Equal(t, "repeatable_read", committed.Isolation)
+	assert.Positive(t, committed.CommitTS)
+	assert.Equal(t, http.StatusNotFound, httpDo(t, srv, "POST", tx+"/commit", "", &herr))
+	assert.Equal(t, "transaction_not_found", herr.Code)
+
+	// 只讀事務拒絕寫入
+	var ro httpTx
+	require.Equal(t, http.StatusCreated, httpDo(t, srv, "POST", "/tx", `{"read_only": true}`, &ro))
+	roPath := fmt.Sprintf("/tx/%d", ro.ID)
+	assert.Equal(t, http.StatusForbidden, httpDo(t, srv, "PUT", roPath+"/keys/key1", `{"value": "x"}`, &herr))
+	assert.Equal(t, "read_only_transaction", herr.Code)
+	var pairs []map[string]string
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "GET", roPath+"/keys?start=a&limit=5", "", &pairs))
+	assert.Equal(t, []map[string]string{{"key": "key1", "value": "v1"}}, pairs)
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "POST", roPath+"/rollback", "", nil))
+
+	assert.Equal(t, http.StatusBadRequest, httpDo(t, srv, "POST", "/tx", `{"isolation": "snapshot"}`, nil))
+}
+
+// 測試包含 "/" 的鍵，路徑中鍵之後的部分都屬於鍵
+func TestHTTPSlashedKey(t *testing.T) {
+	srv := httptest.NewServer(httpapi.New(mvcc.NewDatabase()))
+	defer srv.Close()
+
+	tx := beginHTTP(t, srv, "repeatable_read")
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", tx+"/keys/users/1/name", `{"value": "alice"}`, nil))
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", tx+"/keys/users/2", `{"value": "bob"}`, nil))
+
+	var kv map[string]string
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "GET", tx+"/keys/users/1/name", "", &kv))
+	assert.Equal(t, map[string]string{"key": "users/1/name", "value": "alice"}, kv)
+	var pairs []map[string]string
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "GET", tx+"/keys", "", &pairs))
+	assert.Equal(t, []map[string]string{
+		{"key": "users/1/name", "value": "alice"},
+		{"key": "users/2", "value": "bob"},
+	}, pairs)
+
+	assert.Equal(t, http.StatusNoContent, httpDo(t, srv, "DELETE", tx+"/keys/users/1/name", "", nil))
+	assert.Equal(t, http.StatusNotFound, httpDo(t, srv, "GET", tx+"/keys/users/1/name", "", nil))
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "GET", tx+"/keys/users/2", "", nil))
+	assert.Equal(t, http.StatusOK, httpDo(t, srv, "POST", tx+"/commit", "", nil))
+}
+
+// 通過 HTTP 演示不可重複讀：ReadCommitted 看到並發提交的新值；
+// 兩個 Serializable 事務寫同一個 key，後提交者得到 409
+func TestHTTPIsolationAnomalies(t *testing.T) {
+	srv := httptest.NewServer(httpapi.New(mvcc.NewDatabase()))
+	defer srv.Close()
+
+	setup := beginHTTP(t, srv, "rc")
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", setup+"/keys/balance", `{"value": "100"}`, nil))
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "POST", setup+"/commit", "", nil))
+
+	rc := beginHTTP(t, srv, "read_committed")
+	rr := beginHTTP(t, srv, "serializable")
+	var kv map[string]string
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "GET", rc+"/keys/balance", "", &kv))
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "GET", rr+"/keys/balance", "", &kv))
+
+	writer := beginHTTP(t, srv, "serializable")
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", writer+"/keys/balance", `{"value": "50"}`, nil))
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", rr+"/keys/balance", `{"value": "70"}`, nil))
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "POST", writer+"/commit", "", nil))
+
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "GET", rc+"/keys/balance", "", &kv))
+	assert.Equal(t, "50", kv["value"], "ReadCommitted 讀到新提交的值")
+
+	var herr httpError
+	assert.Equal(t, http.StatusConflict, httpDo(t, srv, "POST", rr+"/commit", "", &herr))
+	assert.Equal(t, "write_conflict", herr.Code)
+}
+
+// 測試查看事務時等待同一事務上被阻塞的寫入結束：寫入者作為死鎖犧牲者被回滾後狀態為 aborted
+func TestHTTPGetDuringBlockedWrite(t *testing.T) {
+	db := mvcc.NewDatabase()
+	srv := httptest.NewServer(httpapi.New(db))
+	defer srv.Close()
+
+	holder := beginHTTP(t, srv, "rr")
+	victim := beginHTTP(t, srv, "rr")
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", holder+"/keys/key1", `{"value": "v1"}`, nil))
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", victim+"/keys/key2", `{"value": "v2"}`, nil))
+
+	var herr httpError
+	written := make(chan int)
+	go func() {
+		written <- httpDo(t, srv, "PUT", victim+"/keys/key1", `{"value": "v2"}`, &herr)
+	}()
+	require.Eventually(t, func() bool {
+		for _, l := range db.Locks() {
+			if l.Waiting {
+				return true
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+
+	var tx httpTx
+	got := make(chan int)
+	go func() {
+		got <- httpDo(t, srv, "GET", victim, "", &tx)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	require.Equal(t, http.StatusOK, httpDo(t, srv, "PUT", holder+"/keys/key2", `{"value": "v1"}`, nil))
+
+	assert.Equal(t, http.StatusConflict, <-written)
+	assert.Equal(t, "deadlock", herr.Code)
+	assert.Equal(t, http.StatusOK, <-got)
+	assert.Equal(t, "aborted", tx.Status)
+
+	// 被回滾的犧牲者不再保留
+	assert.Equal(t, http.StatusNotFound, httpDo(t, srv, "GET", victim, "", &herr))
+	assert.Equal(t, "transaction_not_found", herr.Code)
+}
+
+// 測試被回收器回滾的事務在下一次開始事務時被清除
+func TestHTTPPruneExpired(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithMaxTransactionLifetime(20 * time.Millisecond))
+	defer db.Close()
+	srv := httptest.NewServer(httpapi.New(db))
+	defer srv.Close()
+
+	abandoned := beginHTTP(t, srv, "rr")
+	var tx httpTx
+	require.Eventually(t, func() bool {
+		httpDo(t, srv, "GET", abandoned, "", &tx)
+		return tx.Status == "aborted"
+	}, time.Second, 5*time.Millisecond)
+
+	beginHTTP(t, srv, "rr")
+	var herr httpError
+	assert.Equal(t, http.StatusNotFound, httpDo(t, srv, "GET", abandoned, "", &herr))
+	assert.Equal(t, "transaction_not_found", herr.Code)
+}
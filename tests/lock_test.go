@@ -0,0 +1,242 @@
+package mvcc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試寫鎖衝突時等待持有者提交
+func TestLockWaitUntilCommit(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(tx1, "key1", "value1"))
+
+	// tx2 在 tx1 提交前開始，RepeatableRead 下會因寫寫衝突失敗，因此使用 ReadCommitted
+	done := make(chan error, 1)
+	tx2 := db.Begin(mvcc.ReadCommitted)
+	go func() {
+		done <- db.Write(tx2, "key1", "value2")
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("tx2 不應該在 tx1 提交前獲得寫鎖: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, db.Commit(tx1))
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("tx1 提交後 tx2 應該獲得寫鎖")
+	}
+	assert.NoError(t, db.Commit(tx2))
+
+	tx3 := db.Begin(mvcc.ReadCommitted)
+	val, err := db.Read(tx3, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", val)
+}
+
+// 測試鎖等待超時
+func TestLockWaitTimeout(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithLockTimeout(30 * time.Millisecond))
+
+	tx1 := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(tx1, "key1", "value1"))
+
+	tx2 := db.Begin(mvcc.RepeatableRead)
+	start := time.Now()
+	err := db.Write(tx2, "key1", "value2")
+	assert.ErrorIs(t, err, mvcc.ErrLockTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// 事務級別的超時覆蓋數據庫的默認值
+	tx3 := db.Begin(mvcc.RepeatableRead)
+	tx3.LockTimeout = 100 * time.Millisecond
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		db.Rollback(tx1)
+	}()
+	assert.NoError(t, db.Write(tx3, "key1", "value3"))
+}
+
+// 測試 ReadCommitted 與 ReadUncommitted 讀取時不加讀鎖：讀取不等待正在寫同一個鍵的事務，讀過的鍵也不阻塞寫入；
+// 寫入仍然等待寫鎖。ReadCommitted 讀到的鍵之後被修改時，衝突在提交時由讀集驗證發現
+func TestReadCommittedReadsDoNotLock(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithLockTimeout(30 * time.Millisecond))
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "v0")
+	}))
+
+	writer := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(writer, "key1", "v1"))
+
+	rc := db.Begin(mvcc.ReadCommitted)
+	val, err := db.Read(rc, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, "v0", val)
+	ru := db.Begin(mvcc.ReadUncommitted)
+	val, err = db.Read(ru, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	// 寫入仍然等待寫鎖
+	blocked := db.Begin(mvcc.ReadCommitted)
+	assert.ErrorIs(t, db.Write(blocked, "key1", "v2"), mvcc.ErrLockTimeout)
+	require.NoError(t, db.Rollback(blocked))
+
+	require.NoError(t, db.Commit(writer))
+	val, err = db.Read(rc, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", val, "ReadCommitted 允許不可重複讀")
+
+	// 讀過 key1 的事務不阻塞之後的寫入
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "v2")
+	}))
+
+	require.NoError(t, db.Write(rc, "key2", "x"))
+	assert.ErrorIs(t, db.Commit(rc), mvcc.ErrSerializationFailure)
+	require.NoError(t, db.Write(ru, "key3", "x"))
+	assert.NoError(t, db.Commit(ru))
+}
+
+// 測試等待隊列先進先出：後到的讀鎖不能越過正在等待的寫鎖
+func TestLockFIFOFairness(t *testing.T) {
+	lm := mvcc.NewLockManager()
+	ctx := context.Background()
+
+	require.NoError(t, lm.AcquireLock(ctx, 1, "key1", mvcc.ReadLock))
+
+	order := make(chan int, 2)
+	go func() {
+		assert.NoError(t, lm.AcquireLock(ctx, 2, "key1", mvcc.WriteLock))
+		order <- 2
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		assert.NoError(t, lm.AcquireLock(ctx, 3, "key1", mvcc.ReadLock))
+		order <- 3
+	}()
+
+	select {
+	case id := <-order:
+		t.Fatalf("事務 %d 不應該在事務 1 釋放前獲得鎖", id)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	lm.ReleaseLock(1, "key1")
+	assert.Equal(t, 2, <-order)
+
+	select {
+	case <-order:
+		t.Fatal("事務 3 應該等待事務 2 釋放寫鎖")
+	case <-time.After(30 * time.Millisecond):
+	}
+
+	lm.ReleaseLock(2, "key1")
+	assert.Equal(t, 3, <-order)
+}
+
+// 測試取消 context 會結束鎖等待
+func TestLockWaitCancel(t *testing.T) {
+	lm := mvcc.NewLockManager()
+	require.NoError(t, lm.AcquireLock(context.Background(), 1, "key1", mvcc.WriteLock))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	err := lm.AcquireLock(ctx, 2, "key1", mvcc.ReadLock)
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// 被取消的請求不應該留在隊列中
+	lm.ReleaseLock(1, "key1")
+	assert.NoError(t, lm.AcquireLock(context.Background(), 3, "key1", mvcc.WriteLock))
+}
+
+// 測試兩個事務以相反順序寫入時的死鎖：發起等待的年輕事務被選為犧牲者
+func TestDeadlockRequesterIsVictim(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.RepeatableRead)
+	tx2 := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(tx1, "key1", "tx1"))
+	require.NoError(t, db.Write(tx2, "key2", "tx2"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- db.Write(tx1, "key2", "tx1")
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	start := time.Now()
+	err := db.Write(tx2, "key1", "tx2")
+	assert.ErrorIs(t, err, mvcc.ErrDeadlock)
+	assert.Less(t, time.Since(start), time.Second, "死鎖應該被立即檢測到而不是等到超時")
+	assert.Equal(t, mvcc.Aborted, tx2.Status)
+
+	// 犧牲者回滾後 tx1 得到鎖
+	assert.NoError(t, <-done)
+	assert.NoError(t, db.Commit(tx1))
+	assert.ErrorIs(t, db.Write(tx2, "key3", "tx2"), mvcc.ErrInvalidTransaction)
+
+	tx3 := db.Begin(mvcc.ReadCommitted)
+	val, err := db.Read(tx3, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, "tx1", val)
+}
+
+// 測試犧牲者是另一個正在等待的事務
+func TestDeadlockWaitingVictim(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.RepeatableRead)
+	tx2 := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(tx1, "key1", "tx1"))
+	require.NoError(t, db.Write(tx2, "key2", "tx2"))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- db.Write(tx2, "key1", "tx2")
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	assert.NoError(t, db.Write(tx1, "key2", "tx1"))
+	assert.ErrorIs(t, <-done, mvcc.ErrDeadlock)
+	assert.Equal(t, mvcc.Aborted, tx2.Status)
+	assert.NoError(t, db.Commit(tx1))
+}
+
+// 測試三個事務形成的環
+func TestDeadlockCycleOfThree(t *testing.T) {
+	lm := mvcc.NewLockManager()
+	ctx := context.Background()
+
+	for i, key := range []string{"a", "b", "c"} {
+		require.NoError(t, lm.AcquireLock(ctx, i+1, key, mvcc.WriteLock))
+	}
+
+	results := make(chan error, 2)
+	go func() { results <- lm.AcquireLock(ctx, 1, "b", mvcc.WriteLock) }()
+	time.Sleep(20 * time.Millisecond)
+	go func() { results <- lm.AcquireLock(ctx, 2, "c", mvcc.WriteLock) }()
+	time.Sleep(20 * time.Millisecond)
+
+	// 事務 3 最年輕，關閉環時自己被選為犧牲者
+	assert.ErrorIs(t, lm.AcquireLock(ctx, 3, "a", mvcc.WriteLock), mvcc.ErrDeadlock)
+
+	lm.ReleaseAll(3)
+	assert.NoError(t, <-results)
+	lm.ReleaseAll(2)
+	assert.NoError(t, <-results)
+}
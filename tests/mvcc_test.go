@@ -3,12 +3,14 @@ package mvcc_test
 import (
 	"fmt"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // 測試基礎功能：事務的寫入與讀取
@@ -59,71 +61,142 @@ func TestBasicTransaction(t *testing.T) {
 	}
 }
 
+// account 以 JSON 編碼的結構體值
+type account struct {
+	Owner   string `json:"owner"`
+	Balance int64  `json:"balance"`
+}
+
+// valueType 一種值類型以及測試中寫入的兩個不同的值
+type valueType[V any] struct {
+	codec  mvcc.Codec[V]
+	v1, v2 V
+}
+
+// store 在新的數據庫上創建該類型的 Store
+func (vt valueType[V]) store() *mvcc.Store[V] {
+	return mvcc.NewStore(mvcc.NewDatabase(), vt.codec)
+}
+
+// eachValueType 分別以字串、整數、結構體和二進制值執行同一個測試
+func eachValueType(t *testing.T,
+	str func(*testing.T, valueType[string]),
+	num func(*testing.T, valueType[int64]),
+	obj func(*testing.T, valueType[account]),
+	blob func(*testing.T, valueType[[]byte]),
+) {
+	t.Run("string", func(t *testing.T) {
+		str(t, valueType[string]{mvcc.StringCodec{}, "value1", "value2"})
+	})
+	t.Run("int64", func(t *testing.T) {
+		num(t, valueType[int64]{mvcc.Int64Codec{}, 1, -2})
+	})
+	t.Run("struct", func(t *testing.T) {
+		obj(t, valueType[account]{mvcc.JSONCodec[account]{}, account{"alice", 100}, account{"alice", 50}})
+	})
+	t.Run("bytes", func(t *testing.T) {
+		blob(t, valueType[[]byte]{mvcc.BytesCodec{}, []byte{0, 1, 2}, []byte{0xff, 0}})
+	})
+}
+
 // 測試隔離級別：Read Uncommitted
 func TestReadUncommitted(t *testing.T) {
-	db := mvcc.NewDatabase()
+	eachValueType(t, testReadUncommitted, testReadUncommitted, testReadUncommitted, testReadUncommitted)
+}
+
+func testReadUncommitted[V any](t *testing.T, vt valueType[V]) {
+	s := vt.store()
 
 	// 事務 1 寫入資料
-	tx1 := db.Begin(mvcc.ReadCommitted)
-	db.Write(tx1, "key1", "value1")
+	tx1 := s.DB().Begin(mvcc.ReadCommitted)
+	require.NoError(t, s.Write(tx1, "key1", vt.v1))
 
 	// 事務 2 嘗試讀取未提交的資料
-	tx2 := db.Begin(mvcc.ReadCommitted)
-	value, err := db.ReadWithIsolation(tx2, "key1", mvcc.ReadUncommitted)
-	if err != nil || value != "value1" {
-		t.Errorf("Read Uncommitted 測試失敗: 預期值 'value1', 實際值 '%s'", value)
-	}
+	tx2 := s.DB().Begin(mvcc.ReadCommitted)
+	value, err := s.ReadWithIsolation(tx2, "key1", mvcc.ReadUncommitted)
+	assert.NoError(t, err)
+	assert.Equal(t, vt.v1, value, "Read Uncommitted 應該讀到未提交的資料")
+}
+
+// 測試讀未提交的事務讀到髒數據或被覆蓋的數據後仍然可以提交
+func TestReadUncommittedCommit(t *testing.T) {
+	db := mvcc.NewDatabase()
+	setup := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(setup, "key2", "old"))
+	require.NoError(t, db.Commit(setup))
+
+	writer := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(writer, "key1", "dirty"))
+
+	reader := db.Begin(mvcc.ReadUncommitted)
+	value, err := db.Read(reader, "key1")
+	require.NoError(t, err)
+	assert.Equal(t, "dirty", value)
+	value, err = db.Read(reader, "key2")
+	require.NoError(t, err)
+	assert.Equal(t, "old", value)
+	require.NoError(t, db.Write(reader, "key3", value))
+
+	require.NoError(t, db.Rollback(writer))
+	other := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(other, "key2", "new"))
+	require.NoError(t, db.Commit(other))
+
+	require.NoError(t, db.Commit(reader))
 }
 
 // 測試隔離級別：Read Committed
 func TestReadCommitted(t *testing.T) {
-	db := mvcc.NewDatabase()
+	eachValueType(t, testReadCommitted, testReadCommitted, testReadCommitted, testReadCommitted)
+}
+
+func testReadCommitted[V any](t *testing.T, vt valueType[V]) {
+	s := vt.store()
 
 	// 事務 1 寫入資料
-	tx1 := db.Begin(mvcc.ReadCommitted)
-	db.Write(tx1, "key1", "value1")
+	tx1 := s.DB().Begin(mvcc.ReadCommitted)
+	require.NoError(t, s.Write(tx1, "key1", vt.v1))
 
 	// 事務 2 嘗試讀取，應該無法讀取未提交的資料
-	tx2 := db.Begin(mvcc.ReadCommitted)
-	value, err := db.ReadWithIsolation(tx2, "key1", mvcc.ReadCommitted)
-	if err == nil && value != "" {
-		t.Errorf("Read Committed 測試失敗: 未提交的資料不應該可見")
-	}
+	tx2 := s.DB().Begin(mvcc.ReadCommitted)
+	_, err := s.ReadWithIsolation(tx2, "key1", mvcc.ReadCommitted)
+	assert.Error(t, err, "Read Committed 下未提交的資料不應該可見")
 
 	// 提交事務 1，然後事務 2 再次讀取
-	db.Commit(tx1)
-	value, err = db.ReadWithIsolation(tx2, "key1", mvcc.ReadCommitted)
-	if err != nil || value != "value1" {
-		t.Errorf("Read Committed 測試失敗: 預期值 'value1', 實際值 '%s'", value)
-	}
+	require.NoError(t, s.DB().Commit(tx1))
+	value, err := s.ReadWithIsolation(tx2, "key1", mvcc.ReadCommitted)
+	assert.NoError(t, err)
+	assert.Equal(t, vt.v1, value)
 }
 
 // 測試隔離級別：Repeatable Read
 func TestRepeatableRead(t *testing.T) {
-	db := mvcc.NewDatabase()
+	eachValueType(t, testRepeatableRead, testRepeatableRead, testRepeatableRead, testRepeatableRead)
+}
+
+func testRepeatableRead[V any](t *testing.T, vt valueType[V]) {
+	s := vt.store()
 
 	// 事務 1 寫入資料並提交
-	tx1 := db.Begin(mvcc.ReadCommitted)
-	db.Write(tx1, "key1", "value1")
-	db.Commit(tx1)
+	tx1 := s.DB().Begin(mvcc.ReadCommitted)
+	require.NoError(t, s.Write(tx1, "key1", vt.v1))
+	require.NoError(t, s.DB().Commit(tx1))
 
 	// 事務 2 開始，讀取快照
-	tx2 := db.Begin(mvcc.ReadCommitted)
-	value, err := db.ReadWithIsolation(tx2, "key1", mvcc.RepeatableRead)
-	if err != nil || value != "value1" {
-		t.Errorf("Repeatable Read 測試失敗: 預期值 'value1', 實際值 '%s'", value)
-	}
+	tx2 := s.DB().Begin(mvcc.ReadCommitted)
+	value, err := s.ReadWithIsolation(tx2, "key1", mvcc.RepeatableRead)
+	assert.NoError(t, err)
+	assert.Equal(t, vt.v1, value)
 
 	// 事務 3 修改資料
-	tx3 := db.Begin(mvcc.ReadCommitted)
-	db.Write(tx3, "key1", "value2")
-	db.Commit(tx3)
+	tx3 := s.DB().Begin(mvcc.ReadCommitted)
+	require.NoError(t, s.Write(tx3, "key1", vt.v2))
+	require.NoError(t, s.DB().Commit(tx3))
 
 	// 事務 2 再次讀取應該仍然看到舊快照
-	value, err = db.ReadWithIsolation(tx2, "key1", mvcc.RepeatableRead)
-	if err != nil || value != "value1" {
-		t.Errorf("Repeatable Read 測試失敗: 預期值 'value1', 實際值 '%s'", value)
-	}
+	value, err = s.ReadWithIsolation(tx2, "key1", mvcc.RepeatableRead)
+	assert.NoError(t, err)
+	assert.Equal(t, vt.v1, value, "Repeatable Read 應該仍然看到舊快照")
 }
 
 // 測試多事務的並發寫入
@@ -237,34 +310,38 @@ func TestMVCC(t *testing.T) {
 
 // 測試事務回滾
 func TestTransactionRollback(t *testing.T) {
-	db := mvcc.NewDatabase()
+	eachValueType(t, testTransactionRollback, testTransactionRollback, testTransactionRollback, testTransactionRollback)
+}
+
+func testTransactionRollback[V any](t *testing.T, vt valueType[V]) {
+	s := vt.store()
 
 	// 寫入初始數據
-	tx1 := db.Begin(mvcc.ReadCommitted)
-	err := db.Write(tx1, "key1", "initial")
+	tx1 := s.DB().Begin(mvcc.ReadCommitted)
+	err := s.Write(tx1, "key1", vt.v1)
 	assert.NoError(t, err)
-	err = db.Commit(tx1)
+	err = s.DB().Commit(tx1)
 	assert.NoError(t, err)
 
 	// 開始新事務並寫入
-	tx2 := db.Begin(mvcc.ReadCommitted)
-	err = db.Write(tx2, "key1", "modified")
+	tx2 := s.DB().Begin(mvcc.ReadCommitted)
+	err = s.Write(tx2, "key1", vt.v2)
 	assert.NoError(t, err)
 
 	// 讀取未提交的修改
-	val, err := db.ReadWithIsolation(tx2, "key1", mvcc.ReadUncommitted)
+	val, err := s.ReadWithIsolation(tx2, "key1", mvcc.ReadUncommitted)
 	assert.NoError(t, err)
-	assert.Equal(t, "modified", val)
+	assert.Equal(t, vt.v2, val)
 
 	// 回滾事務
-	err = db.Rollback(tx2)
+	err = s.DB().Rollback(tx2)
 	assert.NoError(t, err)
 
 	// 驗證數據恢復到初始狀態
-	tx3 := db.Begin(mvcc.ReadCommitted)
-	val, err = db.Read(tx3, "key1")
+	tx3 := s.DB().Begin(mvcc.ReadCommitted)
+	val, err = s.Read(tx3, "key1")
 	assert.NoError(t, err)
-	assert.Equal(t, "initial", val)
+	assert.Equal(t, vt.v1, val)
 }
 
 // 測試並發讀寫衝突
@@ -359,10 +436,24 @@ func TestVersionChainIntegrity(t *testing.T) {
 		assert.NoError(t, err)
 	}
 
+	// 同一事務多次寫入同一個 key 只產生一個版本，提交的是最後一次寫入
+	tx := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx, "key1", "v4-draft"))
+	assert.NoError(t, db.Delete(tx, "key1"))
+	assert.NoError(t, db.Write(tx, "key1", "v4"))
+	assert.Len(t, db.GetData()["key1"].GetVersions(), len(versions)+1)
+	assert.NoError(t, db.Commit(tx))
+	versions = append(versions, "v4")
+
 	// 檢查版本鏈
 	record := db.GetData()["key1"]
 	allVersions := record.GetVersions()
 	assert.Equal(t, len(versions), len(allVersions))
+	for i, v := range allVersions {
+		assert.True(t, v.Committed, "版本 %d 應該已提交", i)
+		assert.False(t, v.Deleted, "版本 %d 不應該是墓碑", i)
+		assert.Equal(t, versions[i], v.Value)
+	}
 
 	// 驗證時間戳遞增
 	for i := 1; i < len(allVersions); i++ {
@@ -378,6 +469,30 @@ func TestVersionChainIntegrity(t *testing.T) {
 	assert.Equal(t, 0, allVersions[len(allVersions)-1].EndTS)
 }
 
+// 測試事務重寫自己的版本時不修改已經被讀到的版本（配合 -race 運行）
+func TestRewriteDuringDirtyRead(t *testing.T) {
+	db := mvcc.NewDatabase()
+	writer := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(writer, "key1", "v0"))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			db.Write(writer, "key1", fmt.Sprintf("v%d", i))
+		}
+	}()
+
+	reader := db.Begin(mvcc.ReadUncommitted)
+	for i := 0; i < 200; i++ {
+		value, err := db.Read(reader, "key1")
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(value, "v"), value)
+	}
+	<-done
+	require.NoError(t, db.Commit(writer))
+}
+
 // 測試大量並發事務
 func TestHighConcurrency(t *testing.T) {
 	db := mvcc.NewDatabase()
@@ -413,17 +528,59 @@ func TestHighConcurrency(t *testing.T) {
 	}
 }
 
+// 測試讀己之寫：各個隔離級別下，事務都能讀到自己尚未提交的寫入
+func TestReadYourOwnWrites(t *testing.T) {
+	levels := []mvcc.IsolationLevel{mvcc.ReadUncommitted, mvcc.ReadCommitted, mvcc.RepeatableRead, mvcc.Serializable}
+	for _, level := range levels {
+		db := mvcc.NewDatabase()
+		init := db.Begin(mvcc.ReadCommitted)
+		assert.NoError(t, db.Write(init, "key2", "old"))
+		assert.NoError(t, db.Commit(init))
+
+		tx := db.Begin(level)
+
+		// 先寫後讀
+		assert.NoError(t, db.Write(tx, "key1", "v1"))
+		val, err := db.Read(tx, "key1")
+		assert.NoError(t, err, "level %d", level)
+		assert.Equal(t, "v1", val, "level %d", level)
+
+		// 寫兩次後讀到最後一次的值
+		assert.NoError(t, db.Write(tx, "key2", "v1"))
+		assert.NoError(t, db.Write(tx, "key2", "v2"))
+		val, err = db.Read(tx, "key2")
+		assert.NoError(t, err, "level %d", level)
+		assert.Equal(t, "v2", val, "level %d", level)
+		val, err = db.ReadWithIsolation(tx, "key2", mvcc.RepeatableRead)
+		assert.NoError(t, err, "level %d", level)
+		assert.Equal(t, "v2", val, "level %d", level)
+
+		// 掃描也能看到自己的寫入與刪除
+		assert.NoError(t, db.Delete(tx, "key2"))
+		_, err = db.Read(tx, "key2")
+		assert.ErrorIs(t, err, mvcc.ErrKeyNotFound, "level %d", level)
+		it, err := db.Scan(tx, "", "", 0)
+		assert.Equal(t, []string{"key1=v1"}, collect(t, it, err), "level %d", level)
+
+		assert.NoError(t, db.Commit(tx), "level %d", level)
+	}
+}
+
 func TestDirtyRead(t *testing.T) {
-	db := mvcc.NewDatabase()
+	eachValueType(t, testDirtyRead, testDirtyRead, testDirtyRead, testDirtyRead)
+}
+
+func testDirtyRead[V any](t *testing.T, vt valueType[V]) {
+	s := vt.store()
 
 	// T1: 寫入但不提交
-	tx1 := db.Begin(mvcc.ReadUncommitted)
-	err := db.Write(tx1, "key1", "value1")
+	tx1 := s.DB().Begin(mvcc.ReadUncommitted)
+	err := s.Write(tx1, "key1", vt.v1)
 	assert.NoError(t, err)
 
 	// T2: 讀取未提交的數據
-	tx2 := db.Begin(mvcc.ReadCommitted)
-	_, err = db.Read(tx2, "key1")
+	tx2 := s.DB().Begin(mvcc.ReadCommitted)
+	_, err = s.Read(tx2, "key1")
 	assert.Error(t, err) // 應該返回錯誤
 }
 
@@ -492,3 +649,137 @@ func TestPhantomRead(t *testing.T) {
 	assert.Equal(t, count1+1, finalCount, 
 		"新事務應該能看到所有更改")
 }
+
+// 測試刪除：刪除之前開始的快照仍然看到舊值，之後的讀取看到 key 不存在
+func TestDeleteTombstone(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx1, "key1", "value1"))
+	assert.NoError(t, db.Write(tx1, "key2", "value2"))
+	assert.NoError(t, db.Commit(tx1))
+
+	snapshot := db.Begin(mvcc.RepeatableRead)
+
+	tx2 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Delete(tx2, "key1"))
+	assert.ErrorIs(t, db.Delete(tx2, "missing"), mvcc.ErrKeyNotFound)
+	assert.NoError(t, db.Commit(tx2))
+
+	val, err := db.ReadWithIsolation(snapshot, "key1", mvcc.RepeatableRead)
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+
+	tx3 := db.Begin(mvcc.ReadCommitted)
+	_, err = db.Read(tx3, "key1")
+	assert.ErrorIs(t, err, mvcc.ErrKeyNotFound)
+	assert.Equal(t, 1, db.CountRange("key0", "key9"))
+
+	// 已經刪除的 key 與從未存在的 key 一樣不能再刪除，包括在同一事務中刪除兩次
+	assert.ErrorIs(t, db.Delete(tx3, "key1"), mvcc.ErrKeyNotFound)
+	assert.NoError(t, db.Delete(tx3, "key2"))
+	assert.ErrorIs(t, db.Delete(tx3, "key2"), mvcc.ErrKeyNotFound)
+	assert.NoError(t, db.Write(tx3, "key2", "value2"))
+
+	it, err := db.Scan(tx3, "key0", "key9", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, it.Len())
+
+	// 刪除後可以重新寫入
+	assert.NoError(t, db.Write(tx3, "key1", "value3"))
+	assert.NoError(t, db.Commit(tx3))
+
+	tx4 := db.Begin(mvcc.ReadCommitted)
+	val, err = db.Read(tx4, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value3", val)
+}
+
+// 測試兩個 ReadCommitted 事務並發刪除同一個鍵：後得到寫鎖的一方返回 ErrKeyNotFound 並釋放寫鎖，只留下一個墓碑
+func TestConcurrentDelete(t *testing.T) {
+	db := mvcc.NewDatabase()
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "value1")
+	}))
+
+	tx1 := db.Begin(mvcc.ReadCommitted)
+	tx2 := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Delete(tx1, "key1"))
+
+	deleted := make(chan error)
+	go func() { deleted <- db.Delete(tx2, "key1") }()
+	require.Eventually(t, func() bool {
+		locks := db.Locks()
+		return len(locks) == 2 && locks[1].Waiting
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, db.Commit(tx1))
+	assert.ErrorIs(t, <-deleted, mvcc.ErrKeyNotFound)
+	assert.Empty(t, db.Locks())
+	require.NoError(t, db.Commit(tx2))
+
+	versions := db.GetData()["key1"].GetVersions()
+	require.Len(t, versions, 2)
+	assert.False(t, versions[0].Deleted)
+	assert.True(t, versions[1].Deleted)
+}
+
+// 測試垃圾回收在沒有快照需要舊值後移除整個記錄
+func TestDeleteGarbageCollection(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx1, "key1", "value1"))
+	assert.NoError(t, db.Commit(tx1))
+
+	snapshot := db.Begin(mvcc.RepeatableRead)
+
+	tx2 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Delete(tx2, "key1"))
+	assert.NoError(t, db.Commit(tx2))
+
+	// 快照仍在使用舊版本，記錄必須保留
+	db.CleanupOldVersions()
+	assert.Contains(t, db.GetData(), "key1")
+	val, err := db.ReadWithIsolation(snapshot, "key1", mvcc.RepeatableRead)
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+	assert.NoError(t, db.Rollback(snapshot))
+
+	db.CleanupOldVersions()
+	assert.NotContains(t, db.GetData(), "key1")
+
+	tx3 := db.Begin(mvcc.ReadCommitted)
+	_, err = db.Read(tx3, "key1")
+	assert.ErrorIs(t, err, mvcc.ErrKeyNotFound)
+}
+
+// 測試晚提交的長事務不會出現在提交前已開始的快照中
+func TestCommitTimestampSnapshotStability(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	init := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(init, "key1", "v1"))
+	assert.NoError(t, db.Commit(init))
+
+	// tx1 先開始，但在 reader 開始之後才提交
+	tx1 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx1, "key1", "v2"))
+	reader := db.Begin(mvcc.RepeatableRead)
+	assert.NoError(t, db.Commit(tx1))
+
+	val, err := db.Read(reader, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", val, "reader 的快照不應該看到之後提交的版本")
+
+	// 版本帶有提交時間戳而不是開始時間戳
+	versions := db.GetData()["key1"].GetVersions()
+	assert.Equal(t, tx1.WriteTS, versions[1].Timestamp)
+	assert.Greater(t, tx1.WriteTS, reader.ReadTS)
+	assert.Equal(t, tx1.WriteTS, versions[0].EndTS)
+
+	later := db.Begin(mvcc.RepeatableRead)
+	val, err = db.Read(later, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "v2", val)
+}
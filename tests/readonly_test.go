@@ -0,0 +1,57 @@
+package mvcc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試只讀事務不加鎖：不等待寫者，也不阻塞寫者
+func TestReadOnlySkipsLocks(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithLockTimeout(50 * time.Millisecond))
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "v1")
+	}))
+
+	// 持有寫鎖的事務不會阻塞只讀事務
+	writer := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(writer, "key1", "v2"))
+	ro := db.BeginReadOnly()
+	val, err := db.Read(ro, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", val)
+	require.NoError(t, db.Commit(writer))
+
+	// 只讀事務讀過的鍵可以立即被寫入，它之後仍然讀到自己的快照
+	require.NoError(t, db.Update(mvcc.RepeatableRead, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "v3")
+	}))
+	val, err = db.Read(ro, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", val)
+
+	it, err := db.Scan(ro, "", "", 0)
+	assert.Equal(t, []string{"key1=v1"}, collect(t, it, err))
+	assert.Empty(t, ro.ReadSet)
+
+	// 讀到的數據已經被覆蓋，但只讀事務提交時不做驗證
+	assert.NoError(t, db.Commit(ro))
+	assert.Equal(t, mvcc.Committed, ro.Status)
+}
+
+// 測試只讀事務拒絕寫入
+func TestReadOnlyRejectsWrites(t *testing.T) {
+	db := mvcc.NewDatabase()
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "v1")
+	}))
+
+	ro := db.BeginReadOnly()
+	assert.ErrorIs(t, db.Write(ro, "key1", "v2"), mvcc.ErrReadOnlyTransaction)
+	assert.ErrorIs(t, db.Delete(ro, "key1"), mvcc.ErrReadOnlyTransaction)
+	assert.Empty(t, db.GetData()["key1"].GetVersions()[1:])
+	assert.NoError(t, db.Rollback(ro))
+}
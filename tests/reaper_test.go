@@ -0,0 +1,71 @@
+package mvcc_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試被遺棄的事務到期後被回滾：釋放鎖、不再阻止垃圾回收，之後的操作返回 ErrTransactionExpired
+func TestReapAbandonedTransaction(t *testing.T) {
+	db := mvcc.NewDatabase(
+		mvcc.WithMaxTransactionLifetime(40*time.Millisecond),
+		mvcc.WithLockTimeout(time.Second),
+	)
+	defer db.Close()
+
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "v1")
+	}))
+
+	abandoned := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(abandoned, "key2", "abandoned"))
+
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "v2")
+	}))
+
+	// 等待 key2 的寫鎖時事務被回滾，寫鎖隨之釋放
+	start := time.Now()
+	require.NoError(t, db.Update(mvcc.RepeatableRead, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key2", "v1")
+	}))
+	assert.Less(t, time.Since(start), 500*time.Millisecond)
+
+	_, err := db.Read(abandoned, "key1")
+	assert.ErrorIs(t, err, mvcc.ErrTransactionExpired)
+	assert.ErrorIs(t, db.Write(abandoned, "key1", "v3"), mvcc.ErrTransactionExpired)
+	assert.ErrorIs(t, db.Commit(abandoned), mvcc.ErrTransactionExpired)
+	assert.Equal(t, mvcc.Aborted, abandoned.Status)
+
+	// 被遺棄的快照不再保留 key1 的舊版本
+	db.CleanupOldVersions()
+	assert.Len(t, db.GetData()["key1"].GetVersions(), 1)
+}
+
+// 測試未到期的事務不受影響
+func TestReapKeepsLiveTransactions(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithMaxTransactionLifetime(time.Minute))
+	defer db.Close()
+
+	tx := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(tx, "key1", "v1"))
+	time.Sleep(10 * time.Millisecond)
+	assert.NoError(t, db.Commit(tx))
+	assert.NoError(t, db.Close())
+}
+
+// 測試極短的存活時間不會讓回收器 panic，事務很快到期
+func TestReapTinyLifetime(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithMaxTransactionLifetime(time.Nanosecond))
+	defer db.Close()
+
+	tx := db.Begin(mvcc.ReadCommitted)
+	assert.Eventually(t, func() bool {
+		return errors.Is(db.Write(tx, "key1", "v1"), mvcc.ErrTransactionExpired)
+	}, time.Second, time.Millisecond)
+}
@@ -0,0 +1,216 @@
+package mvcc_test
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// respConn 測試用的最小 RESP 客戶端，回應以 Go 值返回：
+// 簡單字串與錯誤加上前綴 "+"、"-"，空值為 nil
+type respConn struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func dialRESP(t *testing.T, db *mvcc.Database) *respConn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := resp.New(db)
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &respConn{t: t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+// dial 向同一個服務端建立另一個連線
+func (c *respConn) dial() *respConn {
+	conn, err := net.Dial("tcp", c.conn.RemoteAddr().String())
+	require.NoError(c.t, err)
+	c.t.Cleanup(func() { conn.Close() })
+	return &respConn{t: c.t, conn: conn, r: bufio.NewReader(conn)}
+}
+
+func (c *respConn) do(args ...string) any {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	_, err := io.WriteString(c.conn, sb.String())
+	require.NoError(c.t, err)
+	return c.read()
+}
+
+func (c *respConn) read() any {
+	line, err := c.r.ReadString('\n')
+	require.NoError(c.t, err)
+	line = strings.TrimSuffix(line, "\r\n")
+	switch line[0] {
+	case '+', '-':
+		return line
+	case ':':
+		n, _ := strconv.Atoi(line[1:])
+		return n
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		buf := make([]byte, n+2)
+		_, err := io.ReadFull(c.r, buf)
+		require.NoError(c.t, err)
+		return string(buf[:n])
+	case '*':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			return nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			items[i] = c.read()
+		}
+		return items
+	}
+	c.t.Fatalf("unexpected reply %q", line)
+	return nil
+}
+
+// 測試自動提交的 GET/SET/DEL/SCAN
+func TestRESPCommands(t *testing.T) {
+	c := dialRESP(t, mvcc.NewDatabase())
+
+	assert.Equal(t, "+PONG", c.do("PING"))
+	assert.Equal(t, "+OK", c.do("SET", "user:1", "alice"))
+	assert.Equal(t, "+OK", c.do("set", "user:2", "bob"))
+	assert.Equal(t, "+OK", c.do("SET", "user:3", "carol"))
+	assert.Equal(t, "+OK", c.do("SET", "other", "x"))
+	assert.Equal(t, "alice", c.do("GET", "user:1"))
+	assert.Nil(t, c.do("GET", "missing"))
+	assert.Equal(t, 1, c.do("DEL", "user:2", "missing"))
+	assert.Nil(t, c.do("GET", "user:2"))
+
+	assert.Equal(t, []any{"2", []any{"other"}}, c.do("SCAN", "0", "COUNT", "2", "MATCH", "o*"))
+	assert.Equal(t, []any{"0", []any{"user:3"}}, c.do("SCAN", "2", "COUNT", "2"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'get' command", c.do("GET"))
+	assert.Equal(t, "-ERR unknown command 'FOO'", c.do("FOO"))
+}
+
+// 測試 SCAN MATCH 使用 Redis 的通配符規則，* 可以跨越 "/"
+func TestRESPScanMatch(t *testing.T) {
+	c := dialRESP(t, mvcc.NewDatabase())
+	for _, key := range []string{"a/b", "a/b/c", "ab", "a[1]", "b*", "c1", "c5"} {
+		assert.Equal(t, "+OK", c.do("SET", key, "x"))
+	}
+
+	for pattern, want := range map[string][]any{
+		"a*":      {"a/b", "a/b/c", "a[1]", "ab"},
+		"a/*":     {"a/b", "a/b/c"},
+		"a?b":     {"a/b"},
+		"*c":      {"a/b/c"},
+		"c[1-3]":  {"c1"},
+		"c[^1]":   {"c5"},
+		`a\[1]`:   {"a[1]"},
+		`b\*`:     {"b*"},
+		"*":       {"a/b", "a/b/c", "a[1]", "ab", "b*", "c1", "c5"},
+		"missing": {},
+	} {
+		assert.Equal(t, []any{"0", want}, c.do("SCAN", "0", "COUNT", "10", "MATCH", pattern), pattern)
+	}
+}
+
+// 測試兩個客戶端同時 DEL 同一個鍵時不會因讀鎖升級為寫鎖而互相死鎖。
+// 關閉重試以便觀察到失敗：後得到寫鎖的事務只可能因鍵已被刪除而衝突
+func TestRESPConcurrentDel(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithRetryPolicy(mvcc.RetryPolicy{MaxAttempts: 1}))
+	c1 := dialRESP(t, db)
+	c2 := c1.dial()
+
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, "+OK", c1.do("SET", "key1", "v1"))
+		replies := make(chan any, 2)
+		for _, c := range []*respConn{c1, c2} {
+			go func() { replies <- c.do("DEL", "key1") }()
+		}
+		got := []any{<-replies, <-replies}
+		assert.Contains(t, got, 1)
+		for _, reply := range got {
+			if reply != 1 && reply != 0 {
+				assert.Contains(t, reply, "-ERR write conflict")
+			}
+		}
+	}
+	assert.Empty(t, db.Locks())
+}
+
+// 測試 MULTI/EXEC 在一個事務中執行，DISCARD 放棄排隊的命令
+func TestRESPMultiExec(t *testing.T) {
+	db := mvcc.NewDatabase()
+	c := dialRESP(t, db)
+
+	assert.Equal(t, "+OK", c.do("MULTI"))
+	assert.Equal(t, "+QUEUED", c.do("SET", "key1", "v1"))
+	assert.Equal(t, "+QUEUED", c.do("GET", "key1"))
+	assert.Equal(t, "+QUEUED", c.do("DEL", "key1", "key2"))
+	assert.Equal(t, []any{"+OK", "v1", 1}, c.do("EXEC"))
+
+	assert.Equal(t, "+OK", c.do("MULTI"))
+	assert.Equal(t, "+QUEUED", c.do("SET", "key1", "v2"))
+	assert.Equal(t, "+OK", c.do("DISCARD"))
+	assert.Nil(t, c.do("GET", "key1"))
+
+	// 排隊時出錯，EXEC 放棄整個事務
+	assert.Equal(t, "+OK", c.do("MULTI"))
+	assert.Equal(t, "+QUEUED", c.do("SET", "key1", "v3"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'set' command", c.do("SET", "key2"))
+	assert.Equal(t, "-EXECABORT Transaction discarded because of previous errors.", c.do("EXEC"))
+	assert.Nil(t, c.do("GET", "key1"))
+	assert.Equal(t, "-ERR EXEC without MULTI", c.do("EXEC"))
+}
+
+// 測試 WATCH 的鍵在 EXEC 之前被其他客戶端修改時，EXEC 返回空陣列
+func TestRESPWatch(t *testing.T) {
+	db := mvcc.NewDatabase()
+	c := dialRESP(t, db)
+
+	assert.Equal(t, "+OK", c.do("SET", "counter", "1"))
+	assert.Equal(t, "+OK", c.do("WATCH", "counter", "created"))
+	assert.Equal(t, "1", c.do("GET", "counter"))
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "counter", "5")
+	}))
+	assert.Equal(t, "+OK", c.do("MULTI"))
+	assert.Equal(t, "+QUEUED", c.do("GET", "counter"))
+	assert.Equal(t, "+QUEUED", c.do("SET", "counter", "2"))
+	assert.Nil(t, c.do("EXEC"))
+	assert.Equal(t, "5", c.do("GET", "counter"))
+
+	// 監視時不存在的鍵被建立同樣使 EXEC 失敗
+	assert.Equal(t, "+OK", c.do("WATCH", "created"))
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "created", "x")
+	}))
+	assert.Equal(t, "+OK", c.do("MULTI"))
+	assert.Equal(t, "+QUEUED", c.do("SET", "counter", "3"))
+	assert.Nil(t, c.do("EXEC"))
+
+	// 沒有修改時 EXEC 成功
+	assert.Equal(t, "+OK", c.do("WATCH", "counter"))
+	assert.Equal(t, "+OK", c.do("MULTI"))
+	assert.Equal(t, "+QUEUED", c.do("SET", "counter", "6"))
+	assert.Equal(t, []any{"+OK"}, c.do("EXEC"))
+	assert.Equal(t, "6", c.do("GET", "counter"))
+}
@@ -0,0 +1,81 @@
+package mvcc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試回滾到保存點：只撤銷保存點之後的寫入，重複寫入的 key 恢復為保存點時的值
+func TestRollbackToSavepoint(t *testing.T) {
+	db := mvcc.NewDatabase()
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key3", "v0")
+	}))
+
+	tx := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(tx, "key1", "a1"))
+	tx.Savepoint("sp1")
+	require.NoError(t, db.Write(tx, "key1", "a2"))
+	require.NoError(t, db.Write(tx, "key2", "b1"))
+	require.NoError(t, db.Delete(tx, "key3"))
+	tx.Savepoint("sp2")
+	require.NoError(t, db.Write(tx, "key2", "b2"))
+
+	require.NoError(t, db.RollbackTo(tx, "sp1"))
+	assert.Equal(t, map[string]string{"key1": "a1"}, tx.WriteSet)
+	assert.Empty(t, tx.DeleteSet)
+	assert.ErrorIs(t, db.RollbackTo(tx, "sp2"), mvcc.ErrSavepointNotFound)
+
+	// 保存點在回滾後保留，可以再次回滾到它
+	require.NoError(t, db.Write(tx, "key2", "b3"))
+	require.NoError(t, db.RollbackTo(tx, "sp1"))
+	require.NoError(t, db.Commit(tx))
+
+	reader := db.Begin(mvcc.ReadCommitted)
+	val, err := db.Read(reader, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "a1", val)
+	val, err = db.Read(reader, "key3")
+	assert.NoError(t, err)
+	assert.Equal(t, "v0", val)
+	_, err = db.Read(reader, "key2")
+	assert.Error(t, err)
+
+	// 每個 key 只留下已提交的版本
+	for key, record := range db.GetData() {
+		for _, v := range record.GetVersions() {
+			assert.True(t, v.Committed, "key %s 不應該有未提交的版本", key)
+		}
+	}
+}
+
+// 測試回滾到保存點時釋放之後獲得的鎖，保存點之前的鎖仍然持有
+func TestRollbackToReleasesLocks(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithLockTimeout(50 * time.Millisecond))
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key3", "v0")
+	}))
+
+	tx := db.Begin(mvcc.RepeatableRead)
+	require.NoError(t, db.Write(tx, "key1", "a1"))
+	_, err := db.Read(tx, "key3")
+	require.NoError(t, err)
+	tx.Savepoint("sp")
+	require.NoError(t, db.Write(tx, "key2", "b1"))
+	require.NoError(t, db.Write(tx, "key3", "v1"))
+	require.NoError(t, db.RollbackTo(tx, "sp"))
+
+	other := db.Begin(mvcc.RepeatableRead)
+	assert.NoError(t, db.Write(other, "key2", "other"))
+	val, err := db.Read(other, "key3")
+	assert.NoError(t, err, "寫鎖應該降回讀鎖")
+	assert.Equal(t, "v0", val)
+	assert.ErrorIs(t, db.Write(other, "key1", "other"), mvcc.ErrLockTimeout)
+	require.NoError(t, db.Rollback(other))
+
+	require.NoError(t, db.Commit(tx))
+}
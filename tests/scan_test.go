@@ -0,0 +1,144 @@
+package mvcc_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(t *testing.T, it *mvcc.Iterator, err error) []string {
+	require.NoError(t, err)
+	var result []string
+	for it.Next() {
+		result = append(result, it.Key()+"="+it.Value())
+	}
+	return result
+}
+
+// 測試正向、反向與限制數量的掃描
+func TestScanOrderAndLimit(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.ReadCommitted)
+	for _, key := range []string{"b", "d", "a", "c", "e"} {
+		require.NoError(t, db.Write(tx1, key, "v"+key))
+	}
+	require.NoError(t, db.Commit(tx1))
+
+	tx2 := db.Begin(mvcc.RepeatableRead)
+	it, err := db.Scan(tx2, "b", "d", 0)
+	assert.Equal(t, []string{"b=vb", "c=vc", "d=vd"}, collect(t, it, err))
+
+	it, err = db.ReverseScan(tx2, "b", "d", 0)
+	assert.Equal(t, []string{"d=vd", "c=vc", "b=vb"}, collect(t, it, err))
+
+	it, err = db.Scan(tx2, "b", "", 2)
+	assert.Equal(t, []string{"b=vb", "c=vc"}, collect(t, it, err))
+
+	it, err = db.ReverseScan(tx2, "", "", 2)
+	assert.Equal(t, []string{"e=ve", "d=vd"}, collect(t, it, err))
+	assert.NoError(t, db.Commit(tx2))
+}
+
+// 測試掃描遵守事務的快照：之後提交的插入與未提交的寫入都不可見
+func TestScanSnapshotVisibility(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(tx1, "key1", "value1"))
+	require.NoError(t, db.Write(tx1, "key3", "value3"))
+	require.NoError(t, db.Commit(tx1))
+
+	reader := db.Begin(mvcc.RepeatableRead)
+
+	tx2 := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(tx2, "key2", "value2"))
+	require.NoError(t, db.Commit(tx2))
+
+	pending := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(pending, "key4", "value4"))
+
+	it, err := db.Scan(reader, "key", "key~", 0)
+	assert.Equal(t, []string{"key1=value1", "key3=value3"}, collect(t, it, err))
+
+	count, err := db.CountRangeTx(reader, "key", "key~")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	// ReadCommitted 每次讀取最新的已提交版本
+	latest := db.Begin(mvcc.ReadCommitted)
+	it, err = db.Scan(latest, "key", "key~", 0)
+	assert.Equal(t, []string{"key1=value1", "key2=value2", "key3=value3"}, collect(t, it, err))
+}
+
+// 測試掃描登記的範圍參與 SSI 衝突檢測
+func TestScanSerializableConflict(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.Serializable)
+	tx2 := db.Begin(mvcc.Serializable)
+
+	it, err := db.Scan(tx1, "task:", "task:~", 0)
+	assert.Empty(t, collect(t, it, err))
+	it, err = db.ReverseScan(tx2, "task:", "task:~", 0)
+	assert.Empty(t, collect(t, it, err))
+
+	require.NoError(t, db.Write(tx1, "task:1", "tx1"))
+	require.NoError(t, db.Write(tx2, "task:2", "tx2"))
+
+	assert.ErrorIs(t, db.Commit(tx1), mvcc.ErrSerializationFailure)
+	assert.NoError(t, db.Commit(tx2))
+}
+
+// 測試大量亂序插入後鍵仍按順序返回
+func TestOrderedIndexLargeKeyspace(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	const n = 2000
+	tx := db.Begin(mvcc.ReadCommitted)
+	for _, i := range rand.Perm(n) {
+		require.NoError(t, db.Write(tx, fmt.Sprintf("key%05d", i), fmt.Sprint(i)))
+	}
+	require.NoError(t, db.Commit(tx))
+
+	assert.Len(t, db.GetData(), n)
+	assert.Equal(t, 100, db.CountRange("key01000", "key01099"))
+
+	reader := db.Begin(mvcc.ReadCommitted)
+	it, err := db.Scan(reader, "", "", 0)
+	require.NoError(t, err)
+	assert.Equal(t, n, it.Len())
+	prev := ""
+	for it.Next() {
+		assert.Less(t, prev, it.Key())
+		prev = it.Key()
+	}
+
+	it, err = db.ReverseScan(reader, "key00010", "key01500", 3)
+	assert.Equal(t, []string{"key01500=1500", "key01499=1499", "key01498=1498"}, collect(t, it, err))
+}
+
+// 測試前綴掃描
+func TestPrefixScan(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx := db.Begin(mvcc.ReadCommitted)
+	for _, key := range []string{"user:1", "user:2", "user;", "users", "order:1", "user:10"} {
+		require.NoError(t, db.Write(tx, key, "v"))
+	}
+	require.NoError(t, db.Commit(tx))
+
+	reader := db.Begin(mvcc.ReadCommitted)
+	it, err := db.PrefixScan(reader, "user:", 0)
+	assert.Equal(t, []string{"user:1=v", "user:10=v", "user:2=v"}, collect(t, it, err))
+
+	it, err = db.PrefixScan(reader, "user:", 1)
+	assert.Equal(t, []string{"user:1=v"}, collect(t, it, err))
+
+	it, err = db.PrefixScan(reader, "missing", 0)
+	assert.Empty(t, collect(t, it, err))
+}
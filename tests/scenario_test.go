@@ -0,0 +1,88 @@
+package mvcc_test
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runScenario(t *testing.T, src string) *simulator.Report {
+	sc, err := simulator.ParseScenario("test.sim", strings.NewReader(src))
+	require.NoError(t, err)
+	db := mvcc.NewDatabase()
+	defer db.Close()
+	return sc.Run(db, io.Discard)
+}
+
+// 測試場景逐步報告結果，失敗的步驟給出實際的結果
+func TestScenarioReport(t *testing.T) {
+	report := runScenario(t, `
+begin t1
+begin t2
+write t1 k "a b"   # 注釋
+write t2 k x   => blocked
+read t1 k      => "a b"
+commit t1      => ok
+await t2       => ok
+commit t2      => error serialization_failure
+get k          => "a b"
+get missing    => error
+read t2 k      => error deadlock
+write t3 k v
+begin t3
+scan t3        => "k=a b"
+scan t3 x      => empty
+`)
+	require.Len(t, report.Steps, 15)
+	assert.Equal(t, 3, report.Failed())
+
+	var out strings.Builder
+	report.Write(&out)
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	assert.Equal(t, `ok      4: write t1 k "a b"`, lines[2])
+	assert.Equal(t, `FAIL    9: commit t2 => error serialization_failure (got error: write conflict: transaction 2 read key "k" at ts 0, conflicting commit at ts 1)`, lines[7])
+	assert.Equal(t, `FAIL   12: read t2 k => error deadlock (got error: invalid transaction)`, lines[10])
+	assert.Equal(t, `FAIL   13: write t3 k v (got error: no transaction named t3, start one with begin t3)`, lines[11])
+	assert.Equal(t, "FAIL test.sim: 3 of 15 steps failed", lines[len(lines)-1])
+}
+
+// 測試場景文件中的錯誤在解析時報告行號
+func TestScenarioParseErrors(t *testing.T) {
+	for src, msg := range map[string]string{
+		"begin t1\nfrobnicate":              `test.sim:2: unknown command "frobnicate", type help for a list of commands`,
+		"write t1 k":                        "test.sim:1: usage: write <tx> <key> <value>",
+		"begin t1\n\nread t1 k =>":          "test.sim:3: missing expectation after =>",
+		"commit t1 => error oops":           `test.sim:1: unknown error code "oops"`,
+		"commit t1 => ok ok":                `test.sim:1: unexpected "ok" after ok`,
+		"# comment => ok\n=> ok":            "test.sim:2: expectation without a command",
+		`write t1 k "=> unterminated => ok`: "test.sim:1: unterminated quoted string",
+	} {
+		_, err := simulator.ParseScenario("test.sim", strings.NewReader(src))
+		assert.EqualError(t, err, msg, src)
+	}
+}
+
+// 測試 mvcc-simulator 附帶的場景全部通過
+func TestScenarioExamples(t *testing.T) {
+	paths, err := filepath.Glob("../cmd/mvcc-simulator/scenarios/*.sim")
+	require.NoError(t, err)
+	require.NotEmpty(t, paths)
+	for _, path := range paths {
+		sc, err := simulator.LoadScenario(path)
+		require.NoError(t, err)
+		db := mvcc.NewDatabase()
+		report := sc.Run(db, io.Discard)
+		db.Close()
+		if report.Failed() > 0 {
+			var out strings.Builder
+			report.Write(&out)
+			t.Errorf("%s", out.String())
+		}
+	}
+}
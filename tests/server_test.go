@@ -0,0 +1,150 @@
+package mvcc_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/client"
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/server"
+	"github.com/Mahopanda/golang-mvcc/pkg/utils"
+	"github.com/Mahopanda/golang-mvcc/pkg/wire"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startServer(t *testing.T, db *mvcc.Database) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := server.New(db)
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+	return l.Addr().String()
+}
+
+// 測試通過客戶端執行事務，服務端的錯誤可以用 errors.Is 判斷
+func TestServerTransactions(t *testing.T) {
+	db := mvcc.NewDatabase()
+	c, err := client.Dial(startServer(t, db))
+	require.NoError(t, err)
+	defer c.Close()
+
+	tx1, err := c.Begin(mvcc.RepeatableRead)
+	require.NoError(t, err)
+	require.NoError(t, tx1.Write("key1", "v1"))
+	require.NoError(t, tx1.Write("key2", "v\x00binary"))
+	val, err := tx1.Read("key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "v1", val)
+	require.NoError(t, tx1.Commit())
+	assert.ErrorIs(t, tx1.Commit(), mvcc.ErrInvalidTransaction)
+
+	tx2, err := c.Begin(mvcc.Serializable)
+	require.NoError(t, err)
+	pairs, err := tx2.ReverseScan("", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []mvcc.KeyValue{{Key: "key2", Value: "v\x00binary"}, {Key: "key1", Value: "v1"}}, pairs)
+	_, err = tx2.Read("missing")
+	assert.ErrorIs(t, err, mvcc.ErrKeyNotFound)
+
+	// 另一個連線上的事務先提交，tx2 之後寫同一個鍵，提交時衝突。
+	// 刪除會讀取 key1 是否存在，tx2 在此之前寫入的話兩者互相讀寫依賴，被中止的會是 tx3
+	other, err := client.Dial(startServer(t, db))
+	require.NoError(t, err)
+	defer other.Close()
+	tx3, err := other.Begin(mvcc.Serializable)
+	require.NoError(t, err)
+	require.NoError(t, tx3.Delete("key1"))
+	require.NoError(t, tx3.Commit())
+	require.NoError(t, tx2.Write("key1", "v2"))
+
+	err = tx2.Commit()
+	assert.ErrorIs(t, err, mvcc.ErrWriteConflict)
+	assert.True(t, mvcc.IsRetryable(err))
+
+	ro, err := c.BeginReadOnly()
+	require.NoError(t, err)
+	assert.ErrorIs(t, ro.Write("key1", "v3"), mvcc.ErrReadOnlyTransaction)
+	pairs, err = ro.Scan("", "", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []mvcc.KeyValue{{Key: "key2", Value: "v\x00binary"}}, pairs)
+	assert.NoError(t, ro.Rollback())
+
+	_, err = c.Begin(mvcc.IsolationLevel(7))
+	assert.EqualError(t, err, "unknown isolation level 7")
+}
+
+// 測試連線斷開時回滾其上的事務並釋放鎖
+func TestServerDisconnectRollsBack(t *testing.T) {
+	db := mvcc.NewDatabase()
+	addr := startServer(t, db)
+
+	c, err := client.Dial(addr)
+	require.NoError(t, err)
+	tx, err := c.Begin(mvcc.RepeatableRead)
+	require.NoError(t, err)
+	require.NoError(t, tx.Write("key1", "abandoned"))
+	require.NoError(t, c.Close())
+
+	require.NoError(t, db.Update(mvcc.RepeatableRead, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "v1")
+	}))
+	assert.Len(t, db.GetData()["key1"].GetVersions(), 1)
+}
+
+// 測試 Close 斷開連線並在返回前回滾連線上的事務，之後 Serve 返回 ErrServerClosed
+func TestServerClose(t *testing.T) {
+	db := mvcc.NewDatabase()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := server.New(db)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+
+	c, err := client.Dial(l.Addr().String())
+	require.NoError(t, err)
+	defer c.Close()
+	tx, err := c.Begin(mvcc.RepeatableRead)
+	require.NoError(t, err)
+	require.NoError(t, tx.Write("key1", "abandoned"))
+
+	require.NoError(t, srv.Close())
+	assert.ErrorIs(t, <-served, server.ErrServerClosed)
+	assert.Empty(t, db.Locks())
+	assert.ErrorIs(t, srv.Serve(l), server.ErrServerClosed)
+	_, err = tx.Read("key1")
+	assert.Error(t, err)
+}
+
+// 測試長度前綴超出範圍的畸形幀返回錯誤，不會讓服務端崩潰
+func TestServerMalformedFrame(t *testing.T) {
+	conn, err := net.Dial("tcp", startServer(t, mvcc.NewDatabase()))
+	require.NoError(t, err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	call := func(payload []byte) (*wire.Decoder, error) {
+		require.NoError(t, wire.WriteFrame(conn, payload))
+		resp, err := wire.ReadFrame(r)
+		require.NoError(t, err)
+		return wire.DecodeResponse(resp)
+	}
+
+	begin := utils.AppendUvarint([]byte{byte(wire.OpBegin)}, int(mvcc.RepeatableRead))
+	d, err := call(wire.AppendBool(begin, false))
+	require.NoError(t, err)
+	handle := d.Int()
+
+	// 鍵的長度前綴為 2^64-1，轉為 int 後是負數
+	read := utils.AppendUvarint([]byte{byte(wire.OpRead)}, handle)
+	_, err = call(binary.AppendUvarint(read, math.MaxUint64))
+	assert.ErrorContains(t, err, utils.ErrShortBuffer.Error())
+
+	// 連線仍然可用
+	write := utils.AppendUvarint([]byte{byte(wire.OpWrite)}, handle)
+	write = utils.AppendString(utils.AppendString(write, "key1"), "v1")
+	_, err = call(write)
+	assert.NoError(t, err)
+}
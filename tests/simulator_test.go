@@ -0,0 +1,162 @@
+package mvcc_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/Mahopanda/golang-mvcc/pkg/simulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// simulate 依次執行命令，返回輸出
+func simulate(t *testing.T, show bool, lines ...string) string {
+	db := mvcc.NewDatabase()
+	defer db.Close()
+	var out strings.Builder
+	s := simulator.NewSession(db, &out)
+	s.Show = show
+	defer s.Close()
+	for _, line := range lines {
+		require.NoError(t, s.Exec(line), line)
+	}
+	return out.String()
+}
+
+// 測試被阻塞的寫入在持有者提交後完成，之後先提交者勝出
+func TestSimulatorBlockedWrite(t *testing.T) {
+	out := simulate(t, true,
+		"begin t1 rr",
+		"begin t2 rr",
+		"write t1 k v1",
+		"write t2 k v2",
+		"commit t1",
+		"commit t2",
+	)
+	assert.Contains(t, out, "t2: write k \"v2\": blocked waiting for write lock on k held by t1\n")
+	assert.Contains(t, out, "  lock k: held by t1 write; waiting t2 write\n")
+	assert.Contains(t, out, "t1: commit: committed at ts 1\nt2: write k \"v2\": ok\n")
+	assert.Contains(t, out, "  k: [t1 @1- \"v1\"] -> [t2 uncommitted \"v2\"]\n")
+	assert.Contains(t, out, "t2: commit: error: write conflict")
+	assert.True(t, strings.HasSuffix(out, "  k: [t1 @1- \"v1\"]\n  locks: (none)\n"), out)
+}
+
+// 測試死鎖中年輕的事務被回滾，另一個事務得到鎖
+func TestSimulatorDeadlock(t *testing.T) {
+	out := simulate(t, false,
+		"begin t1",
+		"begin t2",
+		"write t1 a 1",
+		"write t2 b 2",
+		"write t1 b 1",
+		"write t2 a 2",
+		"txs",
+	)
+	assert.Contains(t, out, "t1: write b \"1\": blocked waiting for write lock on b held by t2\n")
+	assert.Contains(t, out, "t2: write a \"2\": error: deadlock detected")
+	assert.Contains(t, out, "(t2 aborted)\nt1: write b \"1\": ok\n")
+	assert.Contains(t, out, "  t1: tx 1 (repeatable_read, read_ts 0) active\n  t2: tx 2 (repeatable_read, read_ts 0) aborted\n")
+}
+
+// 測試回滾被阻塞的事務、不可重複讀以及命令錯誤
+func TestSimulatorCommands(t *testing.T) {
+	db := mvcc.NewDatabase()
+	defer db.Close()
+	var out strings.Builder
+	s := simulator.NewSession(db, &out)
+	s.Show = false
+	defer s.Close()
+
+	for _, line := range []string{
+		"begin setup",
+		`write setup "a key" "hello world"  # 帶空白的鍵和值`,
+		"commit setup",
+		"begin t1 rc",
+		"begin t2 rr",
+		"read t1 \"a key\"",
+		"write t2 \"a key\" x",
+		"write t1 \"a key\" y",
+		"rollback t1",
+		"commit t2",
+		"begin t1 read_committed",
+		"read t1 \"a key\"",
+		"scan t1",
+		"gc",
+	} {
+		require.NoError(t, s.Exec(line), line)
+	}
+	assert.Equal(t, `setup: began tx 1 (repeatable_read, read_ts 0)
+setup: write "a key" "hello world": ok
+setup: commit: committed at ts 1
+t1: began tx 2 (read_committed, read_ts 1)
+t2: began tx 3 (repeatable_read, read_ts 1)
+t1: read "a key": "hello world"
+t2: write "a key" "x": ok
+t1: write "a key" "y": blocked waiting for write lock on "a key" held by t2
+t1: write "a key" "y": error: transaction 2 aborted: context canceled (t1 aborted)
+t1: rollback: rolled back
+t2: commit: committed at ts 2
+t1: began tx 4 (read_committed, read_ts 2)
+t1: read "a key": "x"
+t1: scan: ["a key=x"]
+gc: removed 1 versions
+`, out.String())
+
+	out.Reset()
+	require.NoError(t, s.Exec("dump"))
+	assert.Equal(t, "  \"a key\": [t2 @2- \"x\"]\n", out.String())
+
+	assert.EqualError(t, s.Exec("read t9 k"), "no transaction named t9, start one with begin t9")
+	assert.EqualError(t, s.Exec("begin t1"), "t1 is still active")
+	assert.EqualError(t, s.Exec("write t1 k"), "usage: write <tx> <key> <value>")
+	assert.EqualError(t, s.Exec("frobnicate"), `unknown command "frobnicate", type help for a list of commands`)
+	assert.Error(t, s.Exec("begin t3 snapshot"))
+	assert.Error(t, s.Exec(`write t1 k "unterminated`))
+}
+
+// 測試犧牲者不是請求者時，請求者在犧牲者回滾後得到鎖，輸出不依賴於調度
+func TestSimulatorDeadlockOtherVictim(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		out := simulate(t, false,
+			"begin t1",
+			"begin t2",
+			"write t2 a 2",
+			"write t1 b 1",
+			"write t2 b 2",
+			"write t1 a 1",
+		)
+		assert.True(t, strings.HasSuffix(out, "t2: write b \"2\": blocked waiting for write lock on b held by t1\n"+
+			"t1: write a \"1\": ok\n"+
+			"t2: write b \"2\": error: deadlock detected: write lock on key \"b\" requested by transaction 2, blocked by transaction 1 (t2 aborted)\n"), out)
+	}
+}
+
+// 測試回滾在掃描中等待讀鎖的事務時不等到鎖超時
+func TestSimulatorRollbackBlockedScan(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithLockTimeout(time.Minute))
+	defer db.Close()
+	var out strings.Builder
+	s := simulator.NewSession(db, &out)
+	s.Show = false
+	defer s.Close()
+
+	for _, line := range []string{
+		"begin setup",
+		"write setup k v0",
+		"commit setup",
+		"begin t1 rr",
+		"begin t2 rr",
+		"write t1 k v1",
+		"scan t2",
+	} {
+		require.NoError(t, s.Exec(line), line)
+	}
+	assert.Contains(t, out.String(), "t2: scan: blocked waiting for read lock on k held by t1\n")
+
+	start := time.Now()
+	require.NoError(t, s.Exec("rollback t2"))
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Contains(t, out.String(), "t2: scan: error: transaction 3 aborted: context canceled (t2 aborted)\nt2: rollback: rolled back\n")
+}
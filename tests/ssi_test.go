@@ -0,0 +1,117 @@
+package mvcc_test
+
+import (
+	"testing"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 初始化兩位值班醫生
+func setupDoctors(t *testing.T) *mvcc.Database {
+	db := mvcc.NewDatabase()
+	tx := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(tx, "doctor:alice", "on"))
+	require.NoError(t, db.Write(tx, "doctor:bob", "on"))
+	require.NoError(t, db.Commit(tx))
+	return db
+}
+
+// 醫生請假前檢查至少還有另一位醫生值班
+func goOffCall(t *testing.T, db *mvcc.Database, tx *mvcc.Transaction, doctor string) {
+	onCall := 0
+	for _, key := range []string{"doctor:alice", "doctor:bob"} {
+		val, err := db.Read(tx, key)
+		require.NoError(t, err)
+		if val == "on" {
+			onCall++
+		}
+	}
+	require.Equal(t, 2, onCall)
+	require.NoError(t, db.Write(tx, doctor, "off"))
+}
+
+// 經典的寫偏斜：兩位醫生同時請假，快照隔離下兩者都會成功，SSI 必須中止其中一個
+func TestWriteSkewDoctorsOnCall(t *testing.T) {
+	db := setupDoctors(t)
+
+	tx1 := db.Begin(mvcc.Serializable)
+	tx2 := db.Begin(mvcc.Serializable)
+	goOffCall(t, db, tx1, "doctor:alice")
+	goOffCall(t, db, tx2, "doctor:bob")
+
+	err1 := db.Commit(tx1)
+	err2 := db.Commit(tx2)
+	assert.True(t, (err1 == nil) != (err2 == nil), "應該只有一個事務提交成功: %v, %v", err1, err2)
+	for _, err := range []error{err1, err2} {
+		if err != nil {
+			assert.ErrorIs(t, err, mvcc.ErrSerializationFailure)
+		}
+	}
+
+	// 至少仍有一位醫生值班
+	tx := db.Begin(mvcc.ReadCommitted)
+	onCall := 0
+	for _, key := range []string{"doctor:alice", "doctor:bob"} {
+		val, err := db.Read(tx, key)
+		assert.NoError(t, err)
+		if val == "on" {
+			onCall++
+		}
+	}
+	assert.Equal(t, 1, onCall)
+}
+
+// 通過範圍計數產生的寫偏斜：每個事務都看到沒有人值班，於是各自插入新的值班記錄
+func TestWriteSkewPredicateRead(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	tx1 := db.Begin(mvcc.Serializable)
+	tx2 := db.Begin(mvcc.Serializable)
+	for _, tx := range []*mvcc.Transaction{tx1, tx2} {
+		count, err := db.CountRangeTx(tx, "oncall:", "oncall:~")
+		require.NoError(t, err)
+		require.Equal(t, 0, count)
+	}
+	require.NoError(t, db.Write(tx1, "oncall:alice", "on"))
+	require.NoError(t, db.Write(tx2, "oncall:bob", "on"))
+
+	// tx1 先提交時已經同時有指入與指出的反依賴，被中止後 tx2 可以提交
+	assert.ErrorIs(t, db.Commit(tx1), mvcc.ErrSerializationFailure)
+	assert.NoError(t, db.Commit(tx2))
+
+	tx := db.Begin(mvcc.Serializable)
+	count, err := db.CountRangeTx(tx, "oncall:", "oncall:~")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+	assert.NoError(t, db.Commit(tx))
+}
+
+// 沒有危險結構的可串行化事務都應該提交成功
+func TestSerializableNoFalsePositive(t *testing.T) {
+	db := setupDoctors(t)
+
+	tx1 := db.Begin(mvcc.Serializable)
+	tx2 := db.Begin(mvcc.Serializable)
+
+	_, err := db.Read(tx1, "doctor:alice")
+	require.NoError(t, err)
+	require.NoError(t, db.Write(tx1, "doctor:alice", "off"))
+
+	_, err = db.Read(tx2, "doctor:bob")
+	require.NoError(t, err)
+	require.NoError(t, db.Write(tx2, "doctor:bob", "off"))
+
+	assert.NoError(t, db.Commit(tx1))
+	assert.NoError(t, db.Commit(tx2))
+
+	// 單向的反依賴 (tx3 讀到舊值，tx4 覆蓋) 可以按 tx3 -> tx4 串行化
+	tx3 := db.Begin(mvcc.Serializable)
+	tx4 := db.Begin(mvcc.Serializable)
+	_, err = db.Read(tx3, "doctor:alice")
+	require.NoError(t, err)
+	require.NoError(t, db.Write(tx4, "doctor:alice", "on"))
+	assert.NoError(t, db.Commit(tx4))
+	assert.NoError(t, db.Commit(tx3))
+}
@@ -0,0 +1,52 @@
+package mvcc_test
+
+import (
+	"testing"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試二進制值經過預寫日誌恢復後保持不變，以及錯誤編碼的處理
+func TestStoreBinaryRecovery(t *testing.T) {
+	dir := t.TempDir()
+	db, err := mvcc.Open(dir)
+	require.NoError(t, err)
+
+	blobs := mvcc.NewStore(db, mvcc.BytesCodec{})
+	counters := mvcc.NewStore[int64](db, mvcc.Int64Codec{})
+	blob := []byte{0, '\n', 0xff, 0, 0x7f}
+
+	tx := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, blobs.Write(tx, "blob", blob))
+	require.NoError(t, counters.Write(tx, "counter", 42))
+	require.NoError(t, db.Write(tx, "text", "not a counter"))
+	require.NoError(t, db.Commit(tx))
+	require.NoError(t, db.Close())
+
+	db, err = mvcc.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+	blobs = mvcc.NewStore(db, mvcc.BytesCodec{})
+	counters = mvcc.NewStore[int64](db, mvcc.Int64Codec{})
+
+	tx = db.Begin(mvcc.RepeatableRead)
+	got, err := blobs.Read(tx, "blob")
+	assert.NoError(t, err)
+	assert.Equal(t, blob, got)
+
+	n, err := counters.Read(tx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+
+	_, err = counters.Read(tx, "text")
+	assert.ErrorIs(t, err, mvcc.ErrInvalidEncoding)
+
+	it, err := db.Scan(tx, "counter", "counter", 0)
+	require.NoError(t, err)
+	require.True(t, it.Next())
+	n, err = counters.Value(it)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), n)
+}
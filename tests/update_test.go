@@ -0,0 +1,116 @@
+package mvcc_test
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試 fn 成功時提交，返回錯誤時回滾並原樣返回錯誤
+func TestUpdateCommitAndRollback(t *testing.T) {
+	db := mvcc.NewDatabase()
+
+	err := db.Update(mvcc.RepeatableRead, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "value1")
+	})
+	require.NoError(t, err)
+
+	errBusiness := errors.New("insufficient balance")
+	calls := 0
+	err = db.Update(mvcc.RepeatableRead, func(tx *mvcc.Transaction) error {
+		calls++
+		require.NoError(t, db.Write(tx, "key1", "value2"))
+		return errBusiness
+	})
+	assert.ErrorIs(t, err, errBusiness)
+	assert.Equal(t, 1, calls, "不可重試的錯誤不應該重新執行")
+
+	err = db.View(func(tx *mvcc.Transaction) error {
+		val, err := db.Read(tx, "key1")
+		assert.Equal(t, "value1", val)
+		return err
+	})
+	assert.NoError(t, err)
+}
+
+// 測試 fn panic 時回滾並釋放鎖，panic 繼續向上拋出
+func TestUpdatePanicRollsBack(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithLockTimeout(100 * time.Millisecond))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		db.Update(mvcc.RepeatableRead, func(tx *mvcc.Transaction) error {
+			require.NoError(t, db.Write(tx, "key1", "value1"))
+			panic("boom")
+		})
+	})
+
+	// 寫鎖已釋放，未提交的版本已移除
+	err := db.Update(mvcc.RepeatableRead, func(tx *mvcc.Transaction) error {
+		_, err := db.Read(tx, "key1")
+		assert.Error(t, err)
+		return db.Write(tx, "key1", "value2")
+	})
+	assert.NoError(t, err)
+}
+
+// 測試並發衝突時自動重試，所有遞增都不會丟失
+func TestUpdateRetriesConflicts(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithRetryPolicy(mvcc.RetryPolicy{
+		MaxAttempts: 100,
+		Backoff:     time.Millisecond,
+		MaxBackoff:  10 * time.Millisecond,
+	}))
+	require.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "counter", "0")
+	}))
+
+	const workers = 10
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			err := db.Update(mvcc.Serializable, func(tx *mvcc.Transaction) error {
+				val, err := db.Read(tx, "counter")
+				if err != nil {
+					return err
+				}
+				n, _ := strconv.Atoi(val)
+				return db.Write(tx, "counter", strconv.Itoa(n+1))
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.NoError(t, db.View(func(tx *mvcc.Transaction) error {
+		val, err := db.Read(tx, "counter")
+		assert.Equal(t, strconv.Itoa(workers), val)
+		return err
+	}))
+}
+
+// 測試達到最大次數後返回最後一次的錯誤
+func TestUpdateMaxAttempts(t *testing.T) {
+	db := mvcc.NewDatabase(mvcc.WithRetryPolicy(mvcc.RetryPolicy{MaxAttempts: 3}))
+
+	calls := 0
+	err := db.Update(mvcc.Serializable, func(tx *mvcc.Transaction) error {
+		calls++
+		return mvcc.ErrSerializationFailure
+	})
+	assert.ErrorIs(t, err, mvcc.ErrSerializationFailure)
+	assert.Equal(t, 3, calls)
+
+	// View 中的寫入被拒絕
+	err = db.View(func(tx *mvcc.Transaction) error {
+		return db.Write(tx, "key1", "value1")
+	})
+	assert.ErrorIs(t, err, mvcc.ErrReadOnlyTransaction)
+}
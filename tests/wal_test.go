@@ -0,0 +1,421 @@
+package mvcc_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Mahopanda/golang-mvcc/pkg/mvcc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 測試重啟後恢復已提交的事務
+func TestWALRecovery(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := mvcc.Open(dir)
+	require.NoError(t, err)
+
+	tx1 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx1, "key1", "value1"))
+	assert.NoError(t, db.Write(tx1, "key2", "value2"))
+	assert.NoError(t, db.Commit(tx1))
+
+	tx2 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx2, "key1", "value1-2"))
+	assert.NoError(t, db.Commit(tx2))
+
+	// 未提交的事務不應該被恢復
+	tx3 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx3, "key3", "value3"))
+	require.NoError(t, db.Close())
+
+	db, err = mvcc.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	tx := db.Begin(mvcc.ReadCommitted)
+	assert.Greater(t, tx.ID, tx2.ID, "恢復後的時間戳應該繼續遞增")
+
+	val, err := db.Read(tx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1-2", val)
+
+	val, err = db.Read(tx, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, "value2", val)
+
+	_, err = db.Read(tx, "key3")
+	assert.ErrorIs(t, err, mvcc.ErrKeyNotFound)
+
+	assert.Len(t, db.GetData()["key1"].GetVersions(), 2)
+}
+
+// 測試提交途中崩潰：日誌尾部只寫了一半的記錄會被丟棄
+func TestWALTornWrite(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := mvcc.Open(dir)
+	require.NoError(t, err)
+
+	tx1 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx1, "key1", "value1"))
+	assert.NoError(t, db.Commit(tx1))
+
+	tx2 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx2, "key1", "value2"))
+	assert.NoError(t, db.Write(tx2, "key2", "value2"))
+	assert.NoError(t, db.Commit(tx2))
+	require.NoError(t, db.Close())
+
+	// 模擬 tx2 的日誌記錄只寫入了一部分
+	segments, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	require.NoError(t, err)
+	require.Len(t, segments, 1)
+	path := segments[0]
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(path, info.Size()-3))
+
+	db, err = mvcc.Open(dir)
+	require.NoError(t, err)
+
+	tx := db.Begin(mvcc.ReadCommitted)
+	val, err := db.Read(tx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+
+	_, err = db.ReadWithIsolation(tx, "key2", mvcc.ReadCommitted)
+	assert.ErrorIs(t, err, mvcc.ErrKeyNotFound)
+	assert.NoError(t, db.Commit(tx))
+
+	// 截斷後的日誌可以繼續追加
+	tx4 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx4, "key2", "value4"))
+	assert.NoError(t, db.Commit(tx4))
+	require.NoError(t, db.Close())
+
+	db, err = mvcc.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	tx = db.Begin(mvcc.ReadCommitted)
+	val, err = db.Read(tx, "key2")
+	assert.NoError(t, err)
+	assert.Equal(t, "value4", val)
+}
+
+// 子進程在事務進行中被直接終止，沒有調用 Close
+func TestWALProcessCrash(t *testing.T) {
+	if dir := os.Getenv("MVCC_CRASH_DIR"); dir != "" {
+		db, err := mvcc.Open(dir)
+		if err != nil {
+			os.Exit(2)
+		}
+		tx1 := db.Begin(mvcc.ReadCommitted)
+		db.Write(tx1, "key1", "value1")
+		if err := db.Commit(tx1); err != nil {
+			os.Exit(2)
+		}
+		tx2 := db.Begin(mvcc.ReadCommitted)
+		db.Write(tx2, "key2", "value2")
+		os.Exit(1)
+	}
+
+	dir := t.TempDir()
+	cmd := exec.Command(os.Args[0], "-test.run=^TestWALProcessCrash$")
+	cmd.Env = append(os.Environ(), "MVCC_CRASH_DIR="+dir)
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	require.Equal(t, 1, exitErr.ExitCode())
+
+	db, err := mvcc.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	tx := db.Begin(mvcc.ReadCommitted)
+	val, err := db.Read(tx, "key1")
+	assert.NoError(t, err)
+	assert.Equal(t, "value1", val)
+
+	_, err = db.Read(tx, "key2")
+	assert.ErrorIs(t, err, mvcc.ErrKeyNotFound)
+}
+
+// 測試檢查點：恢復從最新快照開始，舊日誌段被刪除
+func TestCheckpointRecovery(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := mvcc.Open(dir)
+	require.NoError(t, err)
+
+	for i, v := range []string{"v1", "v2", "v3"} {
+		tx := db.Begin(mvcc.ReadCommitted)
+		assert.NoError(t, db.Write(tx, "key1", v))
+		if i == 0 {
+			assert.NoError(t, db.Write(tx, "key2", "value2"))
+		}
+		assert.NoError(t, db.Commit(tx))
+	}
+
+	// 檢查點時仍未提交的事務在之後提交，必須從新日誌段恢復
+	pending := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(pending, "key3", "value3"))
+
+	require.NoError(t, db.Checkpoint())
+	assert.NoError(t, db.Commit(pending))
+
+	tx := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx, "key1", "v4"))
+	assert.NoError(t, db.Commit(tx))
+	require.NoError(t, db.Close())
+
+	segments, err := filepath.Glob(filepath.Join(dir, "wal-*.log"))
+	require.NoError(t, err)
+	assert.Len(t, segments, 1, "檢查點之前的日誌段應該被刪除")
+
+	checkpoints, err := filepath.Glob(filepath.Join(dir, "checkpoint-*"))
+	require.NoError(t, err)
+	assert.Len(t, checkpoints, 1)
+
+	db, err = mvcc.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	reader := db.Begin(mvcc.ReadCommitted)
+	assert.Greater(t, reader.ID, tx.ID)
+	for key, want := range map[string]string{"key1": "v4", "key2": "value2", "key3": "value3"} {
+		val, err := db.Read(reader, key)
+		assert.NoError(t, err)
+		assert.Equal(t, want, val, key)
+	}
+
+	// 快照只保存每個鍵的最新版本
+	assert.Len(t, db.GetData()["key1"].GetVersions(), 2)
+}
+
+// 測試連續多次檢查點只保留最新的檢查點
+func TestRepeatedCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := mvcc.Open(dir)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		tx := db.Begin(mvcc.ReadCommitted)
+		assert.NoError(t, db.Write(tx, "counter", string(rune('a'+i))))
+		assert.NoError(t, db.Commit(tx))
+		require.NoError(t, db.Checkpoint())
+	}
+	require.NoError(t, db.Close())
+
+	checkpoints, err := filepath.Glob(filepath.Join(dir, "checkpoint-*"))
+	require.NoError(t, err)
+	assert.Len(t, checkpoints, 1)
+
+	db, err = mvcc.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	tx := db.Begin(mvcc.ReadCommitted)
+	val, err := db.Read(tx, "counter")
+	assert.NoError(t, err)
+	assert.Equal(t, "c", val)
+
+	assert.ErrorIs(t, mvcc.NewDatabase().Checkpoint(), mvcc.ErrNotPersistent)
+}
+
+// 測試檢查點與並發的提交、垃圾回收交錯進行：檢查點不阻塞提交，
+// 恢復後每個鍵都是最後提交的值，刪除的鍵仍然不存在
+func TestCheckpointConcurrentCommits(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := mvcc.Open(dir, mvcc.WithGC(mvcc.GCPolicy{Interval: time.Millisecond, BatchSize: 4}))
+	require.NoError(t, err)
+
+	const writers, rounds = 4, 50
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= rounds; i++ {
+				assert.NoError(t, db.Update(mvcc.ReadCommitted, func(tx *mvcc.Transaction) error {
+					if err := db.Write(tx, fmt.Sprintf("key%d", w), fmt.Sprint(i)); err != nil {
+						return err
+					}
+					if i%2 == 0 {
+						return db.Delete(tx, fmt.Sprintf("tmp%d", w))
+					}
+					return db.Write(tx, fmt.Sprintf("tmp%d", w), fmt.Sprint(i))
+				}))
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	// 寫入結束後再做一次檢查點，最後的日誌段為空
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+		require.NoError(t, db.Checkpoint())
+	}
+	require.NoError(t, db.Close())
+
+	db, err = mvcc.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	tx := db.BeginReadOnly()
+	for w := 0; w < writers; w++ {
+		val, err := db.Read(tx, fmt.Sprintf("key%d", w))
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprint(rounds), val)
+		_, err = db.Read(tx, fmt.Sprintf("tmp%d", w))
+		assert.ErrorIs(t, err, mvcc.ErrKeyNotFound)
+	}
+}
+
+// 測試刪除在重啟與檢查點之後仍然有效
+func TestWALDeleteRecovery(t *testing.T) {
+	dir := t.TempDir()
+
+	db, err := mvcc.Open(dir)
+	require.NoError(t, err)
+
+	tx1 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Write(tx1, "key1", "value1"))
+	assert.NoError(t, db.Write(tx1, "key2", "value2"))
+	assert.NoError(t, db.Commit(tx1))
+
+	tx2 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Delete(tx2, "key1"))
+	assert.NoError(t, db.Commit(tx2))
+	require.NoError(t, db.Checkpoint())
+
+	tx3 := db.Begin(mvcc.ReadCommitted)
+	assert.NoError(t, db.Delete(tx3, "key2"))
+	assert.NoError(t, db.Commit(tx3))
+	require.NoError(t, db.Close())
+
+	db, err = mvcc.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	tx := db.Begin(mvcc.ReadCommitted)
+	for _, key := range []string{"key1", "key2"} {
+		_, err := db.Read(tx, key)
+		assert.ErrorIs(t, err, mvcc.ErrKeyNotFound, key)
+	}
+	assert.NotContains(t, db.GetData(), "key1", "已刪除的鍵不應該寫入檢查點")
+}
+
+// faultyFile 按設置讓日誌段的寫入只寫一半、接下來的若干次同步失敗或截斷失敗
+type faultyFile struct {
+	mvcc.WALFile
+	shortWrite, failTruncate bool
+	syncFailures             int
+}
+
+var errInjected = errors.New("injected failure")
+
+func (f *faultyFile) Write(p []byte) (int, error) {
+	if f.shortWrite {
+		n, _ := f.WALFile.Write(p[:len(p)/2])
+		return n, errInjected
+	}
+	return f.WALFile.Write(p)
+}
+
+func (f *faultyFile) Sync() error {
+	if f.syncFailures > 0 {
+		f.syncFailures--
+		return errInjected
+	}
+	return f.WALFile.Sync()
+}
+
+func (f *faultyFile) Truncate(size int64) error {
+	if f.failTruncate {
+		return errInjected
+	}
+	return f.WALFile.Truncate(size)
+}
+
+// 測試追加日誌失敗時截斷寫了一半或未同步的記錄：失敗的事務不會在重啟後出現，之後的提交不會丟失
+func TestWALAppendFailure(t *testing.T) {
+	dir := t.TempDir()
+	var file *faultyFile
+	db, err := mvcc.Open(dir, mvcc.WithWALFile(func(f mvcc.WALFile) mvcc.WALFile {
+		file = &faultyFile{WALFile: f}
+		return file
+	}))
+	require.NoError(t, err)
+
+	write := func(key, value string) error {
+		tx := db.Begin(mvcc.ReadCommitted)
+		require.NoError(t, db.Write(tx, key, value))
+		return db.Commit(tx)
+	}
+	require.NoError(t, write("key1", "value1"))
+
+	file.shortWrite = true
+	assert.ErrorIs(t, write("key2", "short"), errInjected)
+	file.shortWrite = false
+
+	file.syncFailures = 1
+	assert.ErrorIs(t, write("key3", "unsynced"), errInjected)
+
+	require.NoError(t, write("key4", "value4"))
+	require.NoError(t, db.Close())
+
+	db, err = mvcc.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+	tx := db.Begin(mvcc.ReadCommitted)
+	for key, want := range map[string]string{"key1": "value1", "key4": "value4"} {
+		val, err := db.Read(tx, key)
+		assert.NoError(t, err)
+		assert.Equal(t, want, val, key)
+	}
+	for _, key := range []string{"key2", "key3"} {
+		_, err := db.Read(tx, key)
+		assert.ErrorIs(t, err, mvcc.ErrKeyNotFound, key)
+	}
+}
+
+// 測試寫入失敗後截斷也失敗時拒絕之後的提交
+func TestWALFailedTruncate(t *testing.T) {
+	dir := t.TempDir()
+	var file *faultyFile
+	db, err := mvcc.Open(dir, mvcc.WithWALFile(func(f mvcc.WALFile) mvcc.WALFile {
+		file = &faultyFile{WALFile: f}
+		return file
+	}))
+	require.NoError(t, err)
+	defer db.Close()
+
+	file.syncFailures, file.failTruncate = 1, true
+	tx := db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(tx, "key1", "value1"))
+	assert.ErrorIs(t, db.Commit(tx), mvcc.ErrWALFailed)
+	assert.Equal(t, mvcc.Aborted, tx.Status)
+
+	file.failTruncate = false
+	tx = db.Begin(mvcc.ReadCommitted)
+	require.NoError(t, db.Write(tx, "key2", "value2"))
+	assert.ErrorIs(t, db.Commit(tx), mvcc.ErrWALFailed)
+}